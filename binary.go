@@ -0,0 +1,183 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// binaryFormat is a binary encoding diffed without converting it to json.
+type binaryFormat struct {
+	name      string
+	unmarshal func(data []byte, v interface{}) error
+	marshal   func(v interface{}) ([]byte, error)
+}
+
+var (
+	cborFormat    = binaryFormat{name: "cbor", unmarshal: cbor.Unmarshal, marshal: cbor.Marshal}
+	msgpackFormat = binaryFormat{name: "msgpack", unmarshal: msgpack.Unmarshal, marshal: msgpack.Marshal}
+)
+
+// CBORDiff works as JSONDiff for CBOR documents, with the same options. The root of both documents must be a map.
+//
+// Integers and floats are numbers compared by value and returned as json.Number, floats with a decimal point.
+// Byte strings are returned as []byte and never equal a text string. Keys that aren't strings are compared by their text,
+// for example "1", and a map with two keys of the same text fails with ErrDuplicateKey.
+func CBORDiff(original, new []byte, optFuncs ...Option) (map[string]interface{}, error) {
+	return cborFormat.diff(original, new, optFuncs)
+}
+
+// CBORPatch returns the differences between the CBOR documents as a merge patch encoded as CBOR: changed values keep
+// their nesting, removed values are null and a changed array is written whole, as CBORDiff returns it with the slice options.
+// Numbers and keys are encoded with the type they had in the new document, or in the original one for removed keys.
+func CBORPatch(original, new []byte, optFuncs ...Option) ([]byte, error) {
+	return cborFormat.patch(original, new, optFuncs)
+}
+
+// MsgPackDiff works as CBORDiff for MessagePack documents.
+func MsgPackDiff(original, new []byte, optFuncs ...Option) (map[string]interface{}, error) {
+	return msgpackFormat.diff(original, new, optFuncs)
+}
+
+// MsgPackPatch returns the differences between the MessagePack documents encoded as MessagePack, as CBORPatch does.
+func MsgPackPatch(original, new []byte, optFuncs ...Option) ([]byte, error) {
+	return msgpackFormat.patch(original, new, optFuncs)
+}
+
+// Decode makes the format available to UseFormat. decodeWith keeps its byte strings as []byte, as CBORDiff does.
+func (f binaryFormat) Decode(data []byte) (interface{}, error) {
+	var v interface{}
+	err := f.unmarshal(data, &v)
+	return v, err
+}
+
+func (f binaryFormat) patch(original, new []byte, optFuncs []Option) ([]byte, error) {
+	opts := newOptions(optFuncs)
+
+	originalMap, origN, err := f.decode("original", original, opts)
+	if err != nil {
+		return nil, err
+	}
+	newMap, newN, err := f.decode("new", new, opts)
+	if err != nil {
+		return nil, err
+	}
+	u := mergePatch{patch: make(map[string]interface{})}
+	err = diffUpdate(originalMap, newMap, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.patch) == 0 {
+		return nil, ErrNoDiff
+	}
+	patch, err := f.marshal(newN.restore(u.patch, "", origN))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEncoding, err)
+	}
+	return patch, nil
+}
+
+func (f binaryFormat) diff(original, new []byte, optFuncs []Option) (map[string]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	originalMap, _, err := f.decode("original", original, opts)
+	if err != nil {
+		return nil, err
+	}
+	newMap, _, err := f.decode("new", new, opts)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := iterateMaps(originalMap, newMap, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// decode decodes the document enforcing the limits of the options, as decodeDocument does for json.
+// The normalizer returned records the keys that weren't strings.
+func (f binaryFormat) decode(name string, data []byte, opts Options) (map[string]interface{}, *normalizer, error) {
+	if opts.MaxBytes > 0 && len(data) > opts.MaxBytes {
+		return nil, nil, &ParseError{Document: name, Format: f.name, Err: fmt.Errorf("%w: %d bytes of %d", ErrMaxBytes, len(data), opts.MaxBytes)}
+	}
+	if err := opts.canceled(); err != nil {
+		return nil, nil, err
+	}
+	var decoded interface{}
+	if err := f.unmarshal(data, &decoded); err != nil {
+		return nil, nil, &ParseError{Document: name, Format: f.name, Err: err}
+	}
+	n := &normalizer{useNumber: true, binary: true, keys: make(map[string]map[string]interface{})}
+	value, err := n.value(reflect.ValueOf(decoded), "")
+	if err != nil {
+		return nil, nil, &ParseError{Document: name, Format: f.name, Err: err}
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, nil, &ParseError{Document: name, Format: f.name, Err: fmt.Errorf("root is %s, not an object", jsonKind(value))}
+	}
+	if opts.MaxDepth > 0 || opts.MaxKeys > 0 || opts.MaxArrayLength > 0 {
+		if err := checkLimits(m, opts, "", 0); err != nil {
+			return nil, nil, err
+		}
+	}
+	return m, n, nil
+}
+
+// restore converts the value at path in its json form back to the types recorded by the normalizer.
+// Keys missing in the document, the removed ones, are looked up in removed.
+func (n *normalizer) restore(v interface{}, path string, removed *normalizer) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eEIN") {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+			if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				return u
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = n.restore(item, joinPath(path, strconv.Itoa(i)), removed)
+		}
+		return items
+	case map[string]interface{}:
+		keys := make(map[string]interface{})
+		for k, val := range v {
+			if key, found := n.keys[path][k]; found {
+				keys[k] = key
+			} else if key, found := removed.keys[path][k]; found && val == nil {
+				keys[k] = key
+			}
+		}
+		if len(keys) == 0 {
+			m := make(map[string]interface{}, len(v))
+			for k, val := range v {
+				m[k] = n.restore(val, joinPath(path, k), removed)
+			}
+			return m
+		}
+		m := make(map[interface{}]interface{}, len(v))
+		for k, val := range v {
+			if key, found := keys[k]; found {
+				m[key] = n.restore(val, joinPath(path, k), removed)
+			} else {
+				m[k] = n.restore(val, joinPath(path, k), removed)
+			}
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,141 @@
+package gobo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestBinaryDiff(t *testing.T) {
+	original := map[interface{}]interface{}{
+		"device":   "sensor-1",
+		"firmware": []byte{1, 2},
+		"reading":  1.5,
+		"count":    uint64(10),
+		"ratio":    2.0,
+		1:          "one",
+	}
+	new := map[interface{}]interface{}{
+		"device":   "sensor-1",
+		"firmware": []byte{1, 3},
+		"reading":  1.5,
+		"count":    uint64(11),
+		"ratio":    int64(2),
+		1:          "uno",
+	}
+	expected := map[string]interface{}{
+		"firmware": []byte{1, 3},
+		"count":    json.Number("11"),
+		"1":        "uno",
+	}
+	t.Run("cbor", func(t *testing.T) {
+		originalBytes, _ := cbor.Marshal(original)
+		newBytes, _ := cbor.Marshal(new)
+		diff, err := CBORDiff(originalBytes, newBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, diff)
+
+		patch, err := CBORPatch(originalBytes, newBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[interface{}]interface{}
+		if err := cbor.Unmarshal(patch, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[interface{}]interface{}{"firmware": []byte{1, 3}, "count": uint64(11), uint64(1): "uno"}, decoded)
+	})
+	t.Run("msgpack", func(t *testing.T) {
+		originalBytes, _ := msgpack.Marshal(map[string]interface{}{"name": "cache", "hits": 10, "data": []byte("a"), "ttl": 1.5})
+		newBytes, _ := msgpack.Marshal(map[string]interface{}{"name": "cache", "hits": 12, "data": []byte("b"), "ttl": 1.5})
+		patch, err := MsgPackPatch(originalBytes, newBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[string]interface{}
+		if err := msgpack.Unmarshal(patch, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"hits": int64(12), "data": []byte("b")}, decoded)
+	})
+	t.Run("byte and text strings", func(t *testing.T) {
+		bytesDoc, _ := cbor.Marshal(map[string]interface{}{"a": []byte{1, 2}})
+		textDoc, _ := cbor.Marshal(map[string]interface{}{"a": "AQI="})
+		diff, err := CBORDiff(bytesDoc, textDoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"a": "AQI="}, diff)
+		diff, err = CBORDiff(textDoc, bytesDoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"a": []byte{1, 2}}, diff)
+
+		patch, err := CBORPatch(bytesDoc, textDoc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[interface{}]interface{}
+		if err := cbor.Unmarshal(patch, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[interface{}]interface{}{"a": "AQI="}, decoded)
+
+		changes, err := Changes(bytesDoc, textDoc, UseFormat("cbor"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/a", Kind: ChangeTypeChanged, Old: []byte{1, 2}, New: "AQI="}}, changes)
+		diff, err = JSONDiff(textDoc, bytesDoc, UseFormat("cbor"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"a": []byte{1, 2}}, diff)
+	})
+	t.Run("keys of each map", func(t *testing.T) {
+		originalBytes, _ := cbor.Marshal(map[interface{}]interface{}{1: "a", 2: "c", "m": map[string]interface{}{"1": "x"}})
+		newBytes, _ := cbor.Marshal(map[interface{}]interface{}{1: "b", "m": map[string]interface{}{"1": "y"}})
+		patch, err := CBORPatch(originalBytes, newBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded map[interface{}]interface{}
+		if err := cbor.Unmarshal(patch, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[interface{}]interface{}{uint64(1): "b", uint64(2): nil, "m": map[interface{}]interface{}{"1": "y"}}
+		assert.Equal(t, expected, decoded)
+
+		duplicated, _ := cbor.Marshal(map[interface{}]interface{}{1: "a", "1": "b"})
+		_, err = CBORDiff(duplicated, newBytes)
+		assert.ErrorIs(t, err, ErrDuplicateKey)
+	})
+	t.Run("options and formats", func(t *testing.T) {
+		originalBytes, _ := cbor.Marshal(map[string]interface{}{"temp": 20.01, "etag": "a"})
+		newBytes, _ := cbor.Marshal(map[string]interface{}{"temp": 20.02, "etag": "b"})
+		_, err := CBORDiff(originalBytes, newBytes, UseExcludePaths("/etag"), UseComparator(EpsilonComparator(0.1)))
+		assert.Equal(t, ErrNoDiff, err)
+
+		changes, err := Changes(originalBytes, newBytes, UseFormat("cbor"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, changes, 2)
+	})
+	t.Run("invalid documents", func(t *testing.T) {
+		var parseErr *ParseError
+		_, err := MsgPackDiff([]byte{0xc1}, []byte{0x80})
+		assert.ErrorAs(t, err, &parseErr)
+		assert.Contains(t, err.Error(), "original msgpack-encoded parse failed")
+		array, _ := cbor.Marshal([]int{1})
+		_, err = CBORDiff(array, array)
+		assert.ErrorAs(t, err, &parseErr)
+		assert.EqualError(t, err, "original cbor-encoded parse failed: root is array, not an object")
+	})
+}
@@ -0,0 +1,367 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+	// ChangeTypeChanged is reported when the value changed its json kind, for example from an object to a string.
+	ChangeTypeChanged ChangeKind = "type-changed"
+)
+
+// Change describes a single difference between two documents.
+// Path is the JSON Pointer of the value, Old is nil for added values and New is nil for removed ones.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Old  interface{}
+	New  interface{}
+}
+
+// Changes returns the list of differences between the given json documents sorted by path.
+//
+// Unlike JSONDiff, nested values keep their full path, fields missing in the new document are reported as removed
+// and slices are compared item by item when both have the same length, otherwise the whole slice is modified.
+// UsePathOptions ignore rules, UseExcludePaths, UseIncludePaths and UseComparator are honored.
+func Changes(original, new []byte, optFuncs ...Option) ([]Change, error) {
+	opts := newOptions(optFuncs)
+
+	var originalVal, newVal interface{}
+	err := decodeDocument("original", original, &originalVal, false, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeDocument("new", new, &newVal, false, opts)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffValues(originalVal, newVal, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, ErrNoDiff
+	}
+	return changes, nil
+}
+
+// Apply applies the changes to the json document in order and returns the resulting document.
+// A change whose parent value is missing in the document returns an error wrapping ErrPathNotFound.
+func Apply(doc []byte, changes []Change) ([]byte, error) {
+	docVal, err := decodeNumbers(doc)
+	if err != nil {
+		return nil, parseError("document", err)
+	}
+	docVal, err = applyChanges(docVal, changes)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(docVal)
+}
+
+// Invert returns the changes that undo the given ones: added values are removed, removed values are added back,
+// modified values get their old value and the order is reversed. Applying them to the new document restores the original.
+func Invert(changes []Change) []Change {
+	inverse := make([]Change, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := Change{Path: changes[i].Path, Kind: changes[i].Kind, Old: changes[i].New, New: changes[i].Old}
+		switch change.Kind {
+		case ChangeAdded:
+			change.Kind = ChangeRemoved
+		case ChangeRemoved:
+			change.Kind = ChangeAdded
+		}
+		inverse = append(inverse, change)
+	}
+	return inverse
+}
+
+// InverseDiff returns the patch that restores the original document after applying a diff returned by JSONDiff.
+// Keys of the diff are looked up in the original document as top-level fields and then as fields of the nested objects
+// in key order. Since JSONDiff only reports changed values and the last one written wins, the value is taken from the last
+// of them whose original value differs from the diff, or from the last one when all of them are equal.
+// Keys not found in the original document are set to nil.
+func InverseDiff(original []byte, diff map[string]interface{}) (map[string]interface{}, error) {
+	var originalMap map[string]interface{}
+	err := json.Unmarshal(original, &originalMap)
+	if err != nil {
+		return nil, parseError("original", err)
+	}
+	parents := make([]string, 0, len(originalMap))
+	for k := range originalMap {
+		parents = append(parents, k)
+	}
+	sort.Strings(parents)
+	inverse := make(map[string]interface{}, len(diff))
+	for k, v := range diff {
+		var candidates []interface{}
+		if origVal, found := originalMap[k]; found {
+			candidates = append(candidates, origVal)
+		}
+		for _, parent := range parents {
+			if nested, ok := originalMap[parent].(map[string]interface{}); ok {
+				if nestedVal, found := nested[k]; found {
+					candidates = append(candidates, nestedVal)
+				}
+			}
+		}
+		inverse[k] = nil
+		if len(candidates) > 0 {
+			inverse[k] = candidates[len(candidates)-1]
+		}
+		for _, candidate := range candidates {
+			if !reflect.DeepEqual(candidate, v) {
+				inverse[k] = candidate
+			}
+		}
+	}
+	return inverse, nil
+}
+
+// diffValues walks both values and collects their differences in path order.
+func diffValues(original, new interface{}, opts Options, path string) ([]Change, error) {
+	valOpts := opts.at(path)
+	if valOpts.Ignore || opts.unchanged(path) {
+		return nil, nil
+	}
+	if origKind, newKind := jsonKind(original), jsonKind(new); origKind != newKind {
+		if valOpts.partial {
+			return nil, nil
+		}
+		if err := checkTypeChange(valOpts, path, original, new); err != nil {
+			return nil, err
+		}
+		return []Change{{Path: path, Kind: ChangeTypeChanged, Old: original, New: new}}, nil
+	}
+	switch new := new.(type) {
+	case map[string]interface{}:
+		if original, ok := original.(map[string]interface{}); ok {
+			return diffObjects(original, new, opts, path)
+		}
+	case []interface{}:
+		if original, ok := original.([]interface{}); ok && len(original) == len(new) {
+			return collectChanges(len(new), opts, func(i int) ([]Change, error) {
+				return diffValues(original[i], new[i], opts, joinPath(path, strconv.Itoa(i)))
+			})
+		}
+	}
+	if valOpts.partial || equalValues(valOpts, original, new) {
+		return nil, nil
+	}
+	return []Change{{Path: path, Kind: ChangeModified, Old: original, New: new}}, nil
+}
+
+func diffObjects(original, new map[string]interface{}, opts Options, path string) ([]Change, error) {
+	keys := make([]string, 0, len(original)+len(new))
+	for k := range original {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, found := original[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return collectChanges(len(keys), opts, func(i int) ([]Change, error) {
+		keyPath := joinPath(path, keys[i])
+		origVal, inOrig := original[keys[i]]
+		newVal, inNew := new[keys[i]]
+		switch {
+		case inOrig && inNew:
+			return diffValues(origVal, newVal, opts, keyPath)
+		case opts.at(keyPath).skipped():
+			return nil, nil
+		case inNew:
+			return []Change{{Path: keyPath, Kind: ChangeAdded, New: newVal}}, nil
+		default:
+			return []Change{{Path: keyPath, Kind: ChangeRemoved, Old: origVal}}, nil
+		}
+	})
+}
+
+// collectChanges concatenates in order the changes of n siblings, compared in parallel with UseParallelism.
+// The error of the first sibling failing is returned.
+func collectChanges(n int, opts Options, diff func(i int) ([]Change, error)) ([]Change, error) {
+	if opts.workers == nil || n < parallelThreshold {
+		var changes []Change
+		for i := range n {
+			itemChanges, err := diff(i)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, itemChanges...)
+		}
+		return changes, nil
+	}
+	results := make([][]Change, n)
+	errs := make([]error, n)
+	opts.forEach(n, func(i int) {
+		results[i], errs[i] = diff(i)
+	})
+	if err := opts.canceled(); err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for i := range n {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		changes = append(changes, results[i]...)
+	}
+	return changes, nil
+}
+
+// jsonKind returns the json kind of a decoded value or of a Go value kept by StructDiff.
+// Byte strings, kept by StructDiff and the binary encodings, have their own kind.
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case []byte:
+		return "bytes"
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return rv.Kind().String()
+	}
+}
+
+// checkTypeChange fails with ErrTypeChanged when the options reject type changes.
+// Changes from or to null are always allowed since nullable fields are common.
+func checkTypeChange(opts Options, path string, original, new interface{}) error {
+	if opts.RejectTypeChange && original != nil && new != nil {
+		return opts.fail(&PathError{Path: path, Old: original, New: new, Reason: fmt.Errorf("%w: %s to %s", ErrTypeChanged, jsonKind(original), jsonKind(new))})
+	}
+	return nil
+}
+
+// applyChanges applies the changes to a copy of the document in order and returns the result.
+// Values of the changes are copied too, so the result never shares maps or slices with them.
+func applyChanges(doc interface{}, changes []Change) (interface{}, error) {
+	doc = deepCopy(doc)
+	var err error
+	for _, change := range changes {
+		doc, err = applyChange(doc, splitPath(change.Path), change)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func applyChange(doc interface{}, segs []string, change Change) (interface{}, error) {
+	if len(segs) == 0 {
+		if change.Kind == ChangeRemoved {
+			return nil, nil
+		}
+		return deepCopy(change.New), nil
+	}
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		if len(segs) == 1 {
+			if change.Kind == ChangeRemoved {
+				delete(doc, segs[0])
+			} else {
+				doc[segs[0]] = deepCopy(change.New)
+			}
+			return doc, nil
+		}
+		child, found := doc[segs[0]]
+		if !found {
+			return nil, &PathError{Path: change.Path, New: change.New, Reason: ErrPathNotFound}
+		}
+		child, err := applyChange(child, segs[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		doc[segs[0]] = child
+		return doc, nil
+	case []interface{}:
+		i, err := strconv.Atoi(segs[0])
+		if err != nil || i < 0 || i > len(doc) || (i == len(doc) && (len(segs) > 1 || change.Kind != ChangeAdded)) {
+			return nil, &PathError{Path: change.Path, New: change.New, Reason: ErrPathNotFound}
+		}
+		if len(segs) == 1 {
+			switch change.Kind {
+			case ChangeRemoved:
+				return append(doc[:i], doc[i+1:]...), nil
+			case ChangeAdded:
+				doc = append(doc[:i], append([]interface{}{deepCopy(change.New)}, doc[i:]...)...)
+			default:
+				doc[i] = deepCopy(change.New)
+			}
+			return doc, nil
+		}
+		child, err := applyChange(doc[i], segs[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		doc[i] = child
+		return doc, nil
+	}
+	return nil, &PathError{Path: change.Path, New: change.New, Reason: ErrPathNotFound}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		cp := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			cp[k] = deepCopy(val)
+		}
+		return cp
+	case []interface{}:
+		cp := make([]interface{}, len(v))
+		for i, val := range v {
+			cp[i] = deepCopy(val)
+		}
+		return cp
+	}
+	return v
+}
+
+// decodeNumbers unmarshals the json document keeping numbers as json.Number so they are encoded back untouched.
+func decodeNumbers(data []byte) (v interface{}, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&v)
+	return v, err
+}
+
+// isAncestor reports whether the JSON Pointer a is an ancestor of b.
+func isAncestor(a, b string) bool {
+	return len(b) > len(a) && b[:len(a)] == a && b[len(a)] == '/'
+}
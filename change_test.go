@@ -0,0 +1,126 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChanges(t *testing.T) {
+	t.Run("added, removed and modified", func(t *testing.T) {
+		original := `{"name":"John", "age":32, "meta":{"country":"Argentina", "tags":["a", "b"]}, "phone":"123"}`
+		new := `{"name":"Jane", "age":32, "meta":{"country":"Brazil", "tags":["a", "c"]}, "email":"jane@mail.com"}`
+		changes, err := Changes([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/email", Kind: ChangeAdded, New: "jane@mail.com"},
+			{Path: "/meta/country", Kind: ChangeModified, Old: "Argentina", New: "Brazil"},
+			{Path: "/meta/tags/1", Kind: ChangeModified, Old: "b", New: "c"},
+			{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"},
+			{Path: "/phone", Kind: ChangeRemoved, Old: "123"},
+		}
+		assert.Equal(t, expected, changes)
+	})
+	t.Run("slices with different length", func(t *testing.T) {
+		changes, err := Changes([]byte(`{"tags":["a"]}`), []byte(`{"tags":["a", "b"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/tags", Kind: ChangeModified, Old: []interface{}{"a"}, New: []interface{}{"a", "b"}}}, changes)
+	})
+	t.Run("filters", func(t *testing.T) {
+		changes, err := Changes([]byte(`{"name":"John", "etag":"a"}`), []byte(`{"name":"Jane"}`), UseExcludePaths("/etag"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"}}, changes)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		_, err := Changes([]byte(`{"name":"John"}`), []byte(`{"name":"John"}`))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
+
+func TestInverse(t *testing.T) {
+	original := `{"id":1014336373145370625, "name":"John", "meta":{"country":"Argentina"}, "tags":["a"], "phone":"123"}`
+	new := `{"id":1014336373145370625, "name":"Jane", "meta":{"country":"Brazil"}, "tags":["a", "b"], "email":"jane@mail.com"}`
+	t.Run("undo changes", func(t *testing.T) {
+		changes, err := Changes([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		applied, err := Apply([]byte(original), changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, new, string(applied))
+		restored, err := Apply(applied, Invert(changes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, original, string(restored))
+	})
+	t.Run("path not found", func(t *testing.T) {
+		_, err := Apply([]byte(`{"name":"John"}`), []Change{{Path: "/meta/country", Kind: ChangeModified, New: "Brazil"}})
+		assert.ErrorIs(t, err, ErrPathNotFound)
+	})
+	t.Run("inverse of JSONDiff", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		inverse, err := InverseDiff([]byte(original), diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"name": "John", "country": "Argentina", "tags": []interface{}{"a"}}
+		assert.Equal(t, expected, inverse)
+	})
+	t.Run("inverse of a key shared by nested objects", func(t *testing.T) {
+		original := `{"b":{"city":"Rosario"}, "a":{"city":"Córdoba"}}`
+		for range 10 {
+			inverse, err := InverseDiff([]byte(original), map[string]interface{}{"city": "Salta"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, map[string]interface{}{"city": "Rosario"}, inverse)
+		}
+	})
+	t.Run("inverse of the nested key that changed", func(t *testing.T) {
+		original := `{"a":{"x":1}, "b":{"x":2}}`
+		diff, err := JSONDiff([]byte(original), []byte(`{"a":{"x":1}, "b":{"x":4}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"x": float64(4)}, diff)
+		inverse, err := InverseDiff([]byte(original), diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"x": float64(2)}, inverse)
+
+		original = `{"x":7, "b":{"x":2}}`
+		diff, err = JSONDiff([]byte(original), []byte(`{"x":7, "b":{"x":4}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"x": float64(4)}, diff)
+		inverse, err = InverseDiff([]byte(original), diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"x": float64(2)}, inverse)
+	})
+	t.Run("rollback query", func(t *testing.T) {
+		db := `{"id":1234, "name": "Gonzalo", "age": 19, "nickname": null}`
+		update := `{"name": "Gonza", "age": 20, "nickname": "gonza"}`
+		query, rollback, err := PatchWithRollbackQuery([]byte(db), []byte(update), "users", "id", true, map[string]string{"name": "full_name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET age=20, full_name='Gonza', nickname='gonza' WHERE id=1234`, query)
+		assert.Equal(t, `UPDATE users SET age=19, full_name='Gonzalo', nickname=NULL WHERE id=1234`, rollback)
+	})
+}
@@ -0,0 +1,162 @@
+package gobo
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Comparator decides if an original value and a new one must be considered equal.
+// When the comparator does not handle the given values it returns ok as false and the next comparator,
+// or the default comparison, is used.
+type Comparator interface {
+	Equal(original, new interface{}) (equal, ok bool)
+}
+
+// ComparatorFunc adapts a function to the Comparator interface.
+type ComparatorFunc func(original, new interface{}) (equal, ok bool)
+
+func (f ComparatorFunc) Equal(original, new interface{}) (equal, ok bool) {
+	return f(original, new)
+}
+
+// TimeComparator considers equal the strings that represent the same instant, even in different formats or time zones.
+// If no layouts are given, time.RFC3339Nano is used. Strings that can't be parsed are left to the next comparator.
+func TimeComparator(layouts ...string) Comparator {
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339Nano}
+	}
+	parse := func(v interface{}) (time.Time, bool) {
+		s, ok := v.(string)
+		if !ok {
+			return time.Time{}, false
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+	return ComparatorFunc(func(original, new interface{}) (bool, bool) {
+		origTime, ok := parse(original)
+		if !ok {
+			return false, false
+		}
+		newTime, ok := parse(new)
+		if !ok {
+			return false, false
+		}
+		return origTime.Equal(newTime), true
+	})
+}
+
+// CaseInsensitiveComparator compares strings ignoring case, useful for emails and usernames.
+func CaseInsensitiveComparator() Comparator {
+	return ComparatorFunc(func(original, new interface{}) (bool, bool) {
+		origStr, ok := original.(string)
+		if !ok {
+			return false, false
+		}
+		newStr, ok := new.(string)
+		if !ok {
+			return false, false
+		}
+		return strings.EqualFold(origStr, newStr), true
+	})
+}
+
+// EpsilonComparator considers equal the numbers whose difference is not greater than epsilon.
+func EpsilonComparator(epsilon float64) Comparator {
+	return ComparatorFunc(func(original, new interface{}) (bool, bool) {
+		origNum, ok := toFloat(original)
+		if !ok {
+			return false, false
+		}
+		newNum, ok := toFloat(new)
+		if !ok {
+			return false, false
+		}
+		return math.Abs(origNum-newNum) <= epsilon, true
+	})
+}
+
+// UUIDComparator compares UUID strings regardless of their case. Strings that aren't UUIDs are left to the next comparator.
+func UUIDComparator() Comparator {
+	return ComparatorFunc(func(original, new interface{}) (bool, bool) {
+		origStr, ok := original.(string)
+		if !ok || !isUUID(origStr) {
+			return false, false
+		}
+		newStr, ok := new.(string)
+		if !ok || !isUUID(newStr) {
+			return false, false
+		}
+		return strings.EqualFold(origStr, newStr), true
+	})
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// equalValues compares the values with the comparators of the options and falls back to a deep comparison.
+func equalValues(opts Options, original, new interface{}) bool {
+	for _, c := range opts.comparators {
+		if equal, ok := c.Equal(original, new); ok {
+			return equal
+		}
+	}
+	origNum, origOk := original.(json.Number)
+	newNum, newOk := new.(json.Number)
+	if origOk && newOk && origNum != newNum {
+		if origInt, err := origNum.Int64(); err == nil {
+			if newInt, err := newNum.Int64(); err == nil {
+				return origInt == newInt
+			}
+		}
+		// integers beyond int64 would be rounded as floats
+		if origInt, ok := big.NewInt(0).SetString(origNum.String(), 10); ok {
+			if newInt, ok := big.NewInt(0).SetString(newNum.String(), 10); ok {
+				return origInt.Cmp(newInt) == 0
+			}
+		}
+		origFloat, origErr := origNum.Float64()
+		newFloat, newErr := newNum.Float64()
+		return origErr == nil && newErr == nil && origFloat == newFloat
+	}
+	return reflect.DeepEqual(original, new)
+}
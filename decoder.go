@@ -0,0 +1,297 @@
+package gobo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Decoder decodes documents of a format other than json for the diff functions.
+// Decode returns the document as nested maps with string keys, slices, strings, numbers, booleans and nil.
+// Other integer and float types, time.Time, []byte, and maps with non-string keys are converted to their json form.
+type Decoder interface {
+	Decode(data []byte) (interface{}, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface.
+type DecoderFunc func(data []byte) (interface{}, error)
+
+func (f DecoderFunc) Decode(data []byte) (interface{}, error) {
+	return f(data)
+}
+
+var decoders = struct {
+	sync.RWMutex
+	m map[string]Decoder
+}{m: map[string]Decoder{
+	"yaml":    DecoderFunc(decodeYAMLValue),
+	"toml":    DecoderFunc(decodeTOML),
+	"ini":     DecoderFunc(decodeINI),
+	"cbor":    cborFormat,
+	"msgpack": msgpackFormat,
+}}
+
+// RegisterDecoder makes the decoder available to UseFormat under the name, replacing the one registered before, if any.
+// "yaml", "toml", "ini", "cbor" and "msgpack" are registered by default.
+func RegisterDecoder(format string, dec Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.m[format] = dec
+}
+
+// UseDecoder makes the diff functions decode the documents with dec instead of as json.
+// Results such as merged documents are still written as json.
+func UseDecoder(dec Decoder) Option {
+	return func(opts *Options) {
+		opts.decoder = dec
+		opts.format = ""
+	}
+}
+
+// UseFormat works as UseDecoder with a decoder given to RegisterDecoder. Unknown formats make the diff fail with ErrUnknownFormat.
+func UseFormat(format string) Option {
+	return func(opts *Options) {
+		decoders.RLock()
+		defer decoders.RUnlock()
+		dec, found := decoders.m[format]
+		if !found {
+			dec = DecoderFunc(func([]byte) (interface{}, error) {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+			})
+		}
+		opts.decoder = dec
+		opts.format = format
+	}
+}
+
+// encoding returns the format of the documents decoded with the options, json unless a decoder was given.
+func (o Options) encoding() string {
+	if o.decoder == nil {
+		return "json"
+	}
+	return o.format
+}
+
+// decodeWith decodes the document with dec into v, which is a pointer to a map or to an empty interface.
+// The binary formats are normalized as their own diff functions do, so byte strings never equal text strings.
+func decodeWith(dec Decoder, data []byte, v interface{}, useNumber bool) error {
+	value, err := dec.Decode(data)
+	if err != nil {
+		return err
+	}
+	n := &normalizer{useNumber: useNumber}
+	if _, ok := dec.(binaryFormat); ok {
+		n.binary = true
+		n.keys = make(map[string]map[string]interface{})
+	}
+	value, err = n.value(reflect.ValueOf(value), "")
+	if err != nil {
+		return err
+	}
+	target := reflect.ValueOf(v).Elem()
+	if value == nil {
+		target.SetZero()
+		return nil
+	}
+	if rv := reflect.ValueOf(value); rv.Type().AssignableTo(target.Type()) {
+		target.Set(rv)
+		return nil
+	}
+	return fmt.Errorf("root is %s, not an object", jsonKind(value))
+}
+
+// normalizer converts decoded values to their json form. With useNumber, numbers are json.Number and floats keep
+// a decimal point so they can be told apart from integers. Byte strings are encoded with base64 unless binary is set,
+// then they're kept as []byte and keys records, by the JSON Pointer of their map, the keys that weren't strings
+// so the values can be encoded back as they were.
+type normalizer struct {
+	useNumber bool
+	binary    bool
+	keys      map[string]map[string]interface{}
+}
+
+// value returns the json form of the value at path. Keys that aren't strings are converted to their text,
+// and a map with two keys of the same text, such as 1 and "1", fails with ErrDuplicateKey.
+func (n *normalizer) value(rv reflect.Value, path string) (interface{}, error) {
+	for rv.Kind() == reflect.Interface || rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	switch v := rv.Interface().(type) {
+	case json.Number:
+		if n.useNumber {
+			return v, nil
+		}
+		return v.Float64()
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case []byte:
+		if n.binary {
+			return v, nil
+		}
+		return base64.StdEncoding.EncodeToString(v), nil
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n.useNumber {
+			return json.Number(strconv.FormatInt(rv.Int(), 10)), nil
+		}
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n.useNumber {
+			return json.Number(strconv.FormatUint(rv.Uint(), 10)), nil
+		}
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if n.useNumber {
+			return floatNumber(rv.Float()), nil
+		}
+		return rv.Float(), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			item, err := n.value(rv.Index(i), joinPath(path, strconv.Itoa(i)))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := (&normalizer{}).value(iter.Key(), path)
+			if err != nil {
+				return nil, err
+			}
+			keyStr := fmt.Sprint(key)
+			if _, found := m[keyStr]; found {
+				return nil, &PathError{Path: path, Reason: fmt.Errorf("%w: %q", ErrDuplicateKey, keyStr)}
+			}
+			if _, ok := key.(string); !ok && n.binary {
+				if n.keys[path] == nil {
+					n.keys[path] = make(map[string]interface{})
+				}
+				n.keys[path][keyStr] = iter.Key().Interface()
+			}
+			val, err := n.value(iter.Value(), joinPath(path, keyStr))
+			if err != nil {
+				return nil, err
+			}
+			m[keyStr] = val
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, rv.Type())
+}
+
+// floatNumber returns the json number of a float, with a decimal point when it's integral.
+func floatNumber(f float64) json.Number {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return json.Number(s)
+}
+
+func decodeYAMLValue(data []byte) (interface{}, error) {
+	doc, err := parseYAML(data, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return doc.value, nil
+}
+
+// decodeTOML decodes a TOML document. Local dates and times are written as in the document, without a time zone.
+func decodeTOML(data []byte) (interface{}, error) {
+	var m map[string]interface{}
+	if err := toml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return tomlTimes(m), nil
+}
+
+func tomlTimes(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = tomlTimes(val)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = tomlTimes(item)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			tomlTimes(item)
+		}
+	case time.Time:
+		switch v.Location().String() {
+		case "datetime-local":
+			return v.Format("2006-01-02T15:04:05.999999999")
+		case "date-local":
+			return v.Format(time.DateOnly)
+		case "time-local":
+			return v.Format("15:04:05.999999999")
+		}
+	}
+	return v
+}
+
+// decodeINI decodes an INI document. Sections are objects and every value is a string, with the quotes around it removed.
+// Keys are separated from values by "=" or ":", and lines starting with ";" or "#" are comments.
+func decodeINI(data []byte) (interface{}, error) {
+	root := make(map[string]interface{})
+	section := root
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == ';' || text[0] == '#' {
+			continue
+		}
+		if text[0] == '[' {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: unclosed section %q", line, text)
+			}
+			name := strings.TrimSpace(text[1 : len(text)-1])
+			if existing, ok := root[name].(map[string]interface{}); ok {
+				section = existing
+			} else {
+				section = make(map[string]interface{})
+				root[name] = section
+			}
+			continue
+		}
+		sep := strings.IndexAny(text, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected key and value in %q", line, text)
+		}
+		value := strings.TrimSpace(text[sep+1:])
+		if unquoted, err := strconv.Unquote(value); err == nil && value[0] == '"' {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		section[strings.TrimSpace(text[:sep])] = value
+	}
+	return root, scanner.Err()
+}
@@ -0,0 +1,97 @@
+package gobo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoders(t *testing.T) {
+	t.Run("toml", func(t *testing.T) {
+		original := `
+title = "service"
+released = 2024-01-01
+[server]
+port = 8080
+timeout = 1.5
+[[backends]]
+host = "a"
+`
+		new := `
+title = "service"
+released = 2024-02-01
+[server]
+port = 9090
+timeout = 1.5
+[[backends]]
+host = "b"
+`
+		changes, err := Changes([]byte(original), []byte(new), UseFormat("toml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/backends/0/host", Kind: ChangeModified, Old: "a", New: "b"},
+			{Path: "/released", Kind: ChangeModified, Old: "2024-01-01", New: "2024-02-01"},
+			{Path: "/server/port", Kind: ChangeModified, Old: float64(8080), New: float64(9090)},
+		}
+		assert.Equal(t, expected, changes)
+
+		query, err := PatchWithQuery([]byte("id = 7\nport = 8080\n"), []byte("id = 7\nport = 9090\n"), "services", "id", true, nil, UseFormat("toml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "UPDATE services SET port=9090 WHERE id=7", query)
+	})
+	t.Run("ini", func(t *testing.T) {
+		original := "; database\nname = app\n[db]\nhost = localhost\nport: 5432\n"
+		new := "name = app\n[db]\nhost = \"db.internal\"\nport: 5432\n"
+		diff, err := JSONDiff([]byte(original), []byte(new), UseFormat("ini"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"host": "db.internal"}, diff)
+
+		_, err = JSONDiff([]byte("[db\n"), []byte(new), UseFormat("ini"))
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr)
+		assert.Contains(t, err.Error(), "original ini-encoded parse failed")
+	})
+	t.Run("registered decoder", func(t *testing.T) {
+		// key=value pairs separated by commas
+		RegisterDecoder("pairs", DecoderFunc(func(data []byte) (interface{}, error) {
+			m := make(map[string]int)
+			for _, pair := range strings.Split(string(data), ",") {
+				k, v, _ := strings.Cut(pair, "=")
+				m[k] = len(v)
+			}
+			return m, nil
+		}))
+		diff, err := JSONDiff([]byte("a=xxx,b=yy"), []byte("a=xxx,b=y"), UseFormat("pairs"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"b": float64(1)}, diff)
+	})
+	t.Run("decoder with numbers", func(t *testing.T) {
+		dec := DecoderFunc(func(data []byte) (interface{}, error) {
+			return map[int]interface{}{1: uint8(2), 2: []float32{0.5}}, nil
+		})
+		doc, err := ParseDocument(nil, UseDecoder(dec))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"1": float64(2), "2": []interface{}{float64(0.5)}}, doc.value)
+		var m map[string]interface{}
+		if err := decodeDocument("new", nil, &m, true, newOptions([]Option{UseDecoder(dec)})); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"1": json.Number("2"), "2": []interface{}{json.Number("0.5")}}, m)
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := JSONDiff([]byte("a"), []byte("b"), UseFormat("xml"))
+		assert.ErrorIs(t, err, ErrUnknownFormat)
+	})
+}
@@ -0,0 +1,214 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DynamoUpdate holds the parameters of a DynamoDB UpdateItem request. Values are plain Go values (strings, int64, float64,
+// booleans, nil, maps and slices) to be converted with an attribute value marshaler.
+type DynamoUpdate struct {
+	Key                       map[string]interface{}
+	UpdateExpression          string
+	ConditionExpression       string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]interface{}
+}
+
+// UseCounterPaths makes PatchWithDynamoUpdate write the numbers matched by the patterns with ADD and the difference
+// of their values, so concurrent increments aren't lost. ADD only takes top-level attributes, so nested numbers
+// are written as SET x = x + :d instead. Patterns follow the same rules as UsePathOptions.
+func UseCounterPaths(patterns ...string) Option {
+	return func(opts *Options) {
+		for _, pattern := range patterns {
+			opts.counters = append(opts.counters, splitPath(pattern))
+		}
+	}
+}
+
+// UseOptimisticLock makes PatchWithDynamoUpdate add a ConditionExpression requiring the field to keep its original value,
+// or to be missing when the original document doesn't have it.
+func UseOptimisticLock(field string) Option {
+	return func(opts *Options) {
+		opts.lockField = field
+	}
+}
+
+// PatchWithDynamoUpdate returns the DynamoDB update that turns the original document into the new one, for the item whose
+// key is the keyField value of the original document. As with PatchWithQuery, rel maps json keys to attribute names.
+//
+// Changed and added values are written with SET and removed ones with REMOVE, using document paths for nested values.
+// Arrays follow the slice options: by default the new items are appended with list_append, UseAddNewSlice appends all
+// of them and UseReplaceSlice sets the new list. See UseCounterPaths and UseOptimisticLock for ADD and the condition.
+func PatchWithDynamoUpdate(original, new []byte, keyField string, rel map[string]string, optFuncs ...Option) (*DynamoUpdate, error) {
+	opts := newOptions(optFuncs)
+
+	var originalVal, newVal map[string]interface{}
+	if err := decodeDocument("original", original, &originalVal, true, opts); err != nil {
+		return nil, err
+	}
+	if err := decodeDocument("new", new, &newVal, true, opts); err != nil {
+		return nil, err
+	}
+	keyVal, found := originalVal[keyField]
+	if keyField == "" || !found {
+		return nil, ErrNoCondition
+	}
+	delete(originalVal, keyField)
+	delete(newVal, keyField)
+
+	u := dynamoUpdate{
+		opts:   opts,
+		rel:    rel,
+		names:  make(map[string]string),
+		update: &DynamoUpdate{ExpressionAttributeNames: make(map[string]string)},
+	}
+	err := diffUpdate(originalVal, newVal, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.sets)+len(u.removes)+len(u.add) == 0 {
+		return nil, ErrNoDiff
+	}
+	var clauses []string
+	if len(u.sets) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(u.sets, ", "))
+	}
+	if len(u.removes) > 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(u.removes, ", "))
+	}
+	if len(u.add) > 0 {
+		clauses = append(clauses, "ADD "+strings.Join(u.add, ", "))
+	}
+	u.update.UpdateExpression = strings.Join(clauses, " ")
+	u.update.Key = map[string]interface{}{u.attribute(keyField): bsonValue(keyVal)}
+	if opts.lockField != "" {
+		lockName := u.name(u.attribute(opts.lockField))
+		if lockVal, found := originalVal[opts.lockField]; found {
+			u.update.ConditionExpression = fmt.Sprintf("%s = %s", lockName, u.value(lockVal))
+		} else {
+			u.update.ConditionExpression = fmt.Sprintf("attribute_not_exists(%s)", lockName)
+		}
+	}
+	return u.update, nil
+}
+
+// dynamoUpdate builds the clauses of the update expression and its placeholders.
+type dynamoUpdate struct {
+	opts               Options
+	rel                map[string]string
+	names              map[string]string
+	sets, removes, add []string
+	update             *DynamoUpdate
+}
+
+// attribute returns the attribute name of a top-level json key.
+func (u *dynamoUpdate) attribute(k string) string {
+	if attr, found := u.rel[k]; found {
+		return attr
+	}
+	return k
+}
+
+// name returns the placeholder of an attribute name, the same one every time it's used.
+func (u *dynamoUpdate) name(attr string) string {
+	if placeholder, found := u.names[attr]; found {
+		return placeholder
+	}
+	placeholder := "#n" + strconv.Itoa(len(u.names))
+	u.names[attr] = placeholder
+	u.update.ExpressionAttributeNames[placeholder] = attr
+	return placeholder
+}
+
+// value returns a new placeholder for the value. ExpressionAttributeValues is only made for the first one,
+// since DynamoDB rejects an empty map.
+func (u *dynamoUpdate) value(v interface{}) string {
+	if u.update.ExpressionAttributeValues == nil {
+		u.update.ExpressionAttributeValues = make(map[string]interface{})
+	}
+	placeholder := ":v" + strconv.Itoa(len(u.update.ExpressionAttributeValues))
+	u.update.ExpressionAttributeValues[placeholder] = bsonValue(v)
+	return placeholder
+}
+
+// attr returns the document path of the keys, with a placeholder for each name.
+func (u *dynamoUpdate) attr(segs []string) string {
+	names := make([]string, len(segs))
+	for i, seg := range segs {
+		if i == 0 {
+			seg = u.attribute(seg)
+		}
+		names[i] = u.name(seg)
+	}
+	return strings.Join(names, ".")
+}
+
+func (u *dynamoUpdate) set(path string, segs []string, original, new interface{}) error {
+	attr := u.attr(segs)
+	if delta, ok := numberDelta(original, new); ok && u.counter(path) {
+		if len(segs) > 1 {
+			u.sets = append(u.sets, fmt.Sprintf("%s = %s + %s", attr, attr, u.value(delta)))
+		} else {
+			u.add = append(u.add, fmt.Sprintf("%s %s", attr, u.value(delta)))
+		}
+		return nil
+	}
+	u.sets = append(u.sets, fmt.Sprintf("%s = %s", attr, u.value(new)))
+	return nil
+}
+
+func (u *dynamoUpdate) remove(_ string, segs []string) error {
+	u.removes = append(u.removes, u.attr(segs))
+	return nil
+}
+
+func (u *dynamoUpdate) array(_ string, segs []string, original, new []interface{}, opts Options) error {
+	switch {
+	case opts.AddNewSlice:
+		attr := u.attr(segs)
+		u.sets = append(u.sets, fmt.Sprintf("%s = list_append(%s, %s)", attr, attr, u.value(new)))
+	case opts.ReplaceSlice:
+		u.sets = append(u.sets, fmt.Sprintf("%s = %s", u.attr(segs), u.value(new)))
+	default:
+		// the name is only registered with a clause, DynamoDB rejects unused placeholders
+		if added := addedItems(original, new, opts); len(added) > 0 {
+			attr := u.attr(segs)
+			u.sets = append(u.sets, fmt.Sprintf("%s = list_append(%s, %s)", attr, attr, u.value(added)))
+		}
+	}
+	return nil
+}
+
+// counter reports whether the path was given to UseCounterPaths.
+func (u *dynamoUpdate) counter(path string) bool {
+	segs := splitPath(path)
+	for _, pattern := range u.opts.counters {
+		if matchPattern(pattern, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// numberDelta returns new minus original when both are json numbers, as an int64 when both are integers.
+func numberDelta(original, new interface{}) (json.Number, bool) {
+	origNum, origOk := original.(json.Number)
+	newNum, newOk := new.(json.Number)
+	if !origOk || !newOk {
+		return "", false
+	}
+	origInt, origErr := origNum.Int64()
+	newInt, newErr := newNum.Int64()
+	if origErr == nil && newErr == nil {
+		return json.Number(strconv.FormatInt(newInt-origInt, 10)), true
+	}
+	origFloat, origErr := origNum.Float64()
+	newFloat, newErr := newNum.Float64()
+	if origErr != nil || newErr != nil {
+		return "", false
+	}
+	return json.Number(strconv.FormatFloat(newFloat-origFloat, 'g', -1, 64)), true
+}
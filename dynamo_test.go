@@ -0,0 +1,78 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoUpdate(t *testing.T) {
+	original := `{"pk":"user#1", "name":"John", "version":3, "visits":10, "phone":"123", "address":{"city":"Rosario"}, "tags":["a"]}`
+	new := `{"pk":"user#1", "name":"Jane", "version":4, "visits":12, "address":{"city":"Córdoba"}, "tags":["a", "b"]}`
+	t.Run("update expression", func(t *testing.T) {
+		update, err := PatchWithDynamoUpdate([]byte(original), []byte(new), "pk", map[string]string{"name": "full_name"},
+			UseCounterPaths("/visits"), UseOptimisticLock("version"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := &DynamoUpdate{
+			Key:                 map[string]interface{}{"pk": "user#1"},
+			UpdateExpression:    "SET #n0.#n1 = :v0, #n2 = :v1, #n4 = list_append(#n4, :v2), #n5 = :v3 REMOVE #n3 ADD #n6 :v4",
+			ConditionExpression: "#n5 = :v5",
+			ExpressionAttributeNames: map[string]string{
+				"#n0": "address", "#n1": "city", "#n2": "full_name", "#n3": "phone", "#n4": "tags", "#n5": "version", "#n6": "visits",
+			},
+			ExpressionAttributeValues: map[string]interface{}{
+				":v0": "Córdoba", ":v1": "Jane", ":v2": []interface{}{"b"}, ":v3": int64(4), ":v4": int64(2), ":v5": int64(3),
+			},
+		}
+		assert.Equal(t, expected, update)
+	})
+	t.Run("slice options", func(t *testing.T) {
+		update, err := PatchWithDynamoUpdate([]byte(`{"id":1, "a":[1], "b":[1]}`), []byte(`{"id":1, "a":[1, 2], "b":[2]}`), "id", nil,
+			UsePathOptions("/a", UseAddNewSlice()), UsePathOptions("/b", UseReplaceSlice()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "SET #n0 = list_append(#n0, :v0), #n1 = :v1", update.UpdateExpression)
+		assert.Equal(t, map[string]interface{}{":v0": []interface{}{int64(1), int64(2)}, ":v1": []interface{}{int64(2)}}, update.ExpressionAttributeValues)
+		assert.Equal(t, map[string]interface{}{"id": int64(1)}, update.Key)
+
+		update, err = PatchWithDynamoUpdate([]byte(`{"id":1, "a":1, "tags":["x", "y"]}`), []byte(`{"id":1, "a":2, "tags":["x"]}`), "id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "SET #n0 = :v0", update.UpdateExpression)
+		assert.Equal(t, map[string]string{"#n0": "a"}, update.ExpressionAttributeNames)
+	})
+	t.Run("nested counters and removals only", func(t *testing.T) {
+		update, err := PatchWithDynamoUpdate([]byte(`{"id":1, "stats":{"views":5}}`), []byte(`{"id":1, "stats":{"views":7}}`), "id", nil,
+			UseCounterPaths("/stats/views"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "SET #n0.#n1 = #n0.#n1 + :v0", update.UpdateExpression)
+		assert.Equal(t, map[string]interface{}{":v0": int64(2)}, update.ExpressionAttributeValues)
+
+		update, err = PatchWithDynamoUpdate([]byte(`{"id":1, "a":1}`), []byte(`{"id":1}`), "id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "REMOVE #n0", update.UpdateExpression)
+		assert.Nil(t, update.ExpressionAttributeValues)
+	})
+	t.Run("lock on a new field", func(t *testing.T) {
+		update, err := PatchWithDynamoUpdate([]byte(`{"id":1, "a":1}`), []byte(`{"id":1, "a":2, "version":1}`), "id", nil, UseOptimisticLock("version"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "SET #n0 = :v0, #n1 = :v1", update.UpdateExpression)
+		assert.Equal(t, "attribute_not_exists(#n1)", update.ConditionExpression)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := PatchWithDynamoUpdate([]byte(original), []byte(new), "id", nil)
+		assert.Equal(t, ErrNoCondition, err)
+		_, err = PatchWithDynamoUpdate([]byte(original), []byte(original), "pk", nil)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
@@ -0,0 +1,118 @@
+package gobo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchWithElasticUpdate returns the body of an Elasticsearch _update request turning the original document into the new one.
+//
+// When every change can be merged into the indexed document, the body is a "doc" partial with the changed values.
+// Otherwise it's a painless "script" with the values as params: removed fields are removed from ctx._source and arrays
+// follow the slice options, adding the new items that aren't in the array by default, every new item with UseAddNewSlice,
+// and with UseReplaceSlice, removing the missing items when no item was added or moved, or replacing the array.
+// Numbers are int64 or float64.
+func PatchWithElasticUpdate(original, new []byte, optFuncs ...Option) (map[string]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	var originalVal, newVal map[string]interface{}
+	if err := decodeDocument("original", original, &originalVal, true, opts); err != nil {
+		return nil, err
+	}
+	if err := decodeDocument("new", new, &newVal, true, opts); err != nil {
+		return nil, err
+	}
+
+	u := elasticUpdate{doc: make(map[string]interface{}), params: make(map[string]interface{})}
+	err := diffUpdate(originalVal, newVal, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.statements) == 0 {
+		return nil, ErrNoDiff
+	}
+	if !u.scripted {
+		return map[string]interface{}{"doc": u.doc}, nil
+	}
+	return map[string]interface{}{
+		"script": map[string]interface{}{
+			"lang":   "painless",
+			"source": strings.Join(u.statements, " "),
+			"params": u.params,
+		},
+	}, nil
+}
+
+// elasticUpdate builds both the doc partial and the script, scripted tells whether the partial isn't enough.
+type elasticUpdate struct {
+	doc        map[string]interface{}
+	statements []string
+	params     map[string]interface{}
+	scripted   bool
+}
+
+// field returns the painless expression of the value at the keys.
+func (u *elasticUpdate) field(segs []string) string {
+	var b strings.Builder
+	b.WriteString("ctx._source")
+	for _, seg := range segs {
+		b.WriteString("[" + painlessString(seg) + "]")
+	}
+	return b.String()
+}
+
+// param returns the reference to a new param holding the value.
+func (u *elasticUpdate) param(v interface{}) string {
+	name := "p" + strconv.Itoa(len(u.params))
+	u.params[name] = bsonValue(v)
+	return "params." + name
+}
+
+func (u *elasticUpdate) set(_ string, segs []string, _, new interface{}) error {
+	parent := u.doc
+	for _, seg := range segs[:len(segs)-1] {
+		child, ok := parent[seg].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			parent[seg] = child
+		}
+		parent = child
+	}
+	parent[segs[len(segs)-1]] = bsonValue(new)
+	u.statements = append(u.statements, fmt.Sprintf("%s = %s;", u.field(segs), u.param(new)))
+	return nil
+}
+
+func (u *elasticUpdate) remove(_ string, segs []string) error {
+	u.scripted = true
+	u.statements = append(u.statements, fmt.Sprintf("%s.remove(%s);", u.field(segs[:len(segs)-1]), painlessString(segs[len(segs)-1])))
+	return nil
+}
+
+func (u *elasticUpdate) array(path string, segs []string, original, new []interface{}, opts Options) error {
+	field := u.field(segs)
+	switch {
+	case opts.AddNewSlice:
+		u.statements = append(u.statements, fmt.Sprintf("%s.addAll(%s);", field, u.param(new)))
+	case opts.ReplaceSlice:
+		removed, ok := removedItems(original, new, opts)
+		if !ok {
+			return u.set(path, segs, original, new)
+		}
+		u.statements = append(u.statements, fmt.Sprintf("%s.removeAll(%s);", field, u.param(removed)))
+	default:
+		added := addedItems(original, new, opts)
+		if len(added) == 0 {
+			return nil
+		}
+		u.statements = append(u.statements, fmt.Sprintf("for (def item : %s) { if (!%s.contains(item)) { %s.add(item); } }", u.param(added), field, field))
+	}
+	u.scripted = true
+	return nil
+}
+
+// painlessString returns the painless literal of the string.
+func painlessString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,57 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElasticUpdate(t *testing.T) {
+	t.Run("doc partial", func(t *testing.T) {
+		body, err := PatchWithElasticUpdate([]byte(`{"name":"John", "age":32, "address":{"city":"Rosario", "zip":"2000"}}`),
+			[]byte(`{"name":"Jane", "age":32, "address":{"city":"Córdoba", "zip":"2000"}, "tags":["a"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"doc": map[string]interface{}{
+				"name":    "Jane",
+				"address": map[string]interface{}{"city": "Córdoba"},
+				"tags":    []interface{}{"a"},
+			},
+		}
+		assert.Equal(t, expected, body)
+	})
+	t.Run("script", func(t *testing.T) {
+		original := `{"name":"John", "phone":"123", "tags":["a"], "history":[1], "roles":["admin", "user"], "o'k":1}`
+		new := `{"name":"Jane", "tags":["a", "b"], "history":[1, 2], "roles":["user"], "o'k":2}`
+		body, err := PatchWithElasticUpdate([]byte(original), []byte(new),
+			UsePathOptions("/history", UseAddNewSlice()), UsePathOptions("/roles", UseReplaceSlice()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"script": map[string]interface{}{
+				"lang": "painless",
+				"source": "ctx._source['history'].addAll(params.p0); " +
+					"ctx._source['name'] = params.p1; " +
+					"ctx._source['o\\'k'] = params.p2; " +
+					"ctx._source.remove('phone'); " +
+					"ctx._source['roles'].removeAll(params.p3); " +
+					"for (def item : params.p4) { if (!ctx._source['tags'].contains(item)) { ctx._source['tags'].add(item); } }",
+				"params": map[string]interface{}{
+					"p0": []interface{}{int64(1), int64(2)},
+					"p1": "Jane",
+					"p2": int64(2),
+					"p3": []interface{}{"admin"},
+					"p4": []interface{}{"b"},
+				},
+			},
+		}
+		assert.Equal(t, expected, body)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		_, err := PatchWithElasticUpdate([]byte(`{"tags":["a", "b"]}`), []byte(`{"tags":["b"]}`))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
@@ -0,0 +1,96 @@
+package gobo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PathError describes a problem found at a value of the documents. Path is its JSON Pointer, Old and New the values involved,
+// and Reason wraps one of the package errors so errors.Is works against them, for example ErrKeyConflict or ErrTypeChanged.
+type PathError struct {
+	Path   string
+	Old    interface{}
+	New    interface{}
+	Reason error
+}
+
+func (e *PathError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return fmt.Sprintf("%v at %s", e.Reason, path)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Reason
+}
+
+// ParseError is returned when one of the documents can't be decoded. Document names the argument ("original", "new"...),
+// Format is the encoding of the document ("json", "yaml", the format given to UseFormat...), empty when it isn't known,
+// and Offset is the byte where decoding failed, when the decoder reports it.
+type ParseError struct {
+	Document string
+	Format   string
+	Offset   int64
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	if e.Format == "" {
+		return fmt.Sprintf("%s document parse failed: %v", e.Document, e.Err)
+	}
+	return fmt.Sprintf("%s %s-encoded parse failed: %v", e.Document, e.Format, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseError returns the ParseError of a json document.
+func parseError(document string, err error) error {
+	parseErr := &ParseError{Document: document, Format: "json", Err: err}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		parseErr.Offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		parseErr.Offset = typeErr.Offset
+	}
+	return parseErr
+}
+
+// collectedErrors are the errors recorded with UseCollectErrors, siblings compared in parallel record them at the same time.
+type collectedErrors struct {
+	mu   sync.Mutex
+	errs []*PathError
+}
+
+// fail returns the error, or records it and returns nil when UseCollectErrors was given so the diff goes on.
+func (o Options) fail(err *PathError) error {
+	if o.errs != nil {
+		o.errs.mu.Lock()
+		o.errs.errs = append(o.errs.errs, err)
+		o.errs.mu.Unlock()
+		return nil
+	}
+	return err
+}
+
+// finish returns the errors collected with UseCollectErrors joined and sorted by path, if there are any, or the given error otherwise.
+func (o Options) finish(err error) error {
+	if o.errs == nil || len(o.errs.errs) == 0 {
+		return err
+	}
+	sort.SliceStable(o.errs.errs, func(i, j int) bool {
+		return o.errs.errs[i].Path < o.errs.errs[j].Path
+	})
+	errs := make([]error, len(o.errs.errs))
+	for i, pathErr := range o.errs.errs {
+		errs[i] = pathErr
+	}
+	return errors.Join(errs...)
+}
@@ -1,47 +1,103 @@
 package gobo
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 )
 
 var (
-	ErrNoDiff      = errors.New("there are no differences between values")
-	ErrKeyConflict = errors.New("keys with equal values have different names")
-	ErrNoCondition = errors.New("method did not receive query conditions")
+	ErrNoDiff          = errors.New("there are no differences between values")
+	ErrKeyConflict     = errors.New("keys with equal values have different names")
+	ErrNoCondition     = errors.New("method did not receive query conditions")
+	ErrMergeConflict   = errors.New("both documents changed the same value")
+	ErrPathNotFound    = errors.New("path not found in document")
+	ErrNotStruct       = errors.New("value is not a struct")
+	ErrTypeChanged     = errors.New("value changed its type")
+	ErrInvalidNumber   = errors.New("number can't be parsed")
+	ErrUnsupportedType = errors.New("value type can't be written in a query")
+	ErrEncoding        = errors.New("value can't be encoded")
+	ErrMaxBytes        = errors.New("document exceeds the maximum size")
+	ErrMaxDepth        = errors.New("document exceeds the maximum depth")
+	ErrMaxKeys         = errors.New("object exceeds the maximum number of keys")
+	ErrMaxArrayLength  = errors.New("array exceeds the maximum length")
+	ErrStreamBuffer    = errors.New("object keys out of order exceed the stream buffer")
+	ErrUnknownFormat   = errors.New("no decoder registered for the format")
+	ErrDuplicateKey    = errors.New("object has two keys with the same text")
 )
 
 // JSONDiff will handle the differences of the given structures.
 // It checks values between original data and the new one and return the differences.
-// Ensure given data is a json in bytes array format.
+// Ensure given data is a json in bytes array format with an object at the root, use JSONDiffValue for arrays and scalars.
 //
 // To configure analysis of slices add UseReplaceSlice or UseAddNewSlice function as 'optFuncs' argument.
 // If nothing is added, it will conserve original slice and add the differences of the new one. Slices with empty items won't throw an ErrEmptyFields like the others structures.
+// Use UsePathOptions to configure a different behavior for specific subtrees, and UseExcludePaths or UseIncludePaths to filter the compared fields.
+// Values are compared deeply unless a Comparator added with UseComparator handles them.
+// Untrusted documents can be bounded with UseMaxBytes, UseMaxDepth, UseMaxKeys and UseMaxArrayLength, see also JSONDiffContext.
 func JSONDiff(original, new []byte, optFuncs ...Option) (diff map[string]interface{}, err error) {
-	opts := Options{}
-	for _, optFunc := range optFuncs {
-		optFunc(&opts)
-	}
+	opts := newOptions(optFuncs)
 
 	var originalMap map[string]interface{}
-	err = json.Unmarshal(original, &originalMap)
+	err = decodeDocument("original", original, &originalMap, false, opts)
 	if err != nil {
-		return nil, fmt.Errorf("original json-encoded parse failed: %w", err)
+		return nil, err
 	}
 	var newMap map[string]interface{}
-	err = json.Unmarshal(new, &newMap)
+	err = decodeDocument("new", new, &newMap, false, opts)
 	if err != nil {
-		return nil, fmt.Errorf("new json-encoded parse failed: %w", err)
+		return nil, err
 	}
 
-	diff, err = iterateMaps(originalMap, newMap, opts)
-	if err != nil {
+	diff, err = iterateMaps(originalMap, newMap, opts, "")
+	if err = opts.finish(err); err != nil {
 		return nil, err
 	}
 	return diff, nil
 }
 
+// JSONDiffValue works as JSONDiff but accepts any json value at the root of the documents.
+// Objects return the same differences as JSONDiff, arrays follow the slice options as any other slice,
+// and scalars return the new value when they are different.
+func JSONDiffValue(original, new []byte, optFuncs ...Option) (diff interface{}, err error) {
+	opts := newOptions(optFuncs)
+
+	var originalVal, newVal interface{}
+	err = decodeDocument("original", original, &originalVal, false, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = decodeDocument("new", new, &newVal, false, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	switch newVal := newVal.(type) {
+	case map[string]interface{}:
+		if originalMap, ok := originalVal.(map[string]interface{}); ok {
+			diff, err := iterateMaps(originalMap, newVal, opts, "")
+			if err = opts.finish(err); err != nil {
+				return nil, err
+			}
+			return diff, nil
+		}
+	case []interface{}:
+		if originalSli, ok := originalVal.([]interface{}); ok {
+			diff, changed, err := diffSlices(originalSli, newVal, opts, "")
+			if err = opts.finish(err); err != nil {
+				return nil, err
+			}
+			if !changed {
+				return nil, ErrNoDiff
+			}
+			return diff, nil
+		}
+	}
+	rootOpts := opts.at("")
+	if rootOpts.skipped() || equalValues(rootOpts, originalVal, newVal) {
+		return nil, ErrNoDiff
+	}
+	return newVal, nil
+}
+
 // PatchWithQuery will do the same tasks as DoPatch but instead of return the differences, it will return a PostgreSQL update query with only the necessary changes to be made.
 //
 // The 'condition' parameter can be completed as you want. It's added after the SET part.
@@ -50,30 +106,34 @@ func JSONDiff(original, new []byte, optFuncs ...Option) (diff map[string]interfa
 // For example: map[string]string{} {"last_name"(json): "lastName"(database)}
 // If ignoreEmpty is true it won't include the empty (string) fields.
 // In the case there are no differences between database and json fields, set 'rel' as nil.
-func PatchWithQuery(original, new []byte, table, condition string, ignoreEmpty bool, rel map[string]string) (query string, err error) {
-	switch condition {
-	case "":
+// Use UseExcludePaths or UseIncludePaths as 'optFuncs' argument to filter the json fields that can be updated.
+// Changed objects and arrays can't be written in the query and return ErrUnsupportedType, null values are written as NULL.
+func PatchWithQuery(original, new []byte, table, condition string, ignoreEmpty bool, rel map[string]string, optFuncs ...Option) (query string, err error) {
+	opts := newOptions(optFuncs)
+
+	if condition == "" {
 		return "", ErrNoCondition
-	case "id", "Id", "ID":
-		diff, idVal, err := findDiffsForQuery(original, new, condition, ignoreEmpty)
-		if err != nil {
-			return "", err
-		}
-		switch idVal := idVal.(type) {
-		case string:
-			set := buildSetClause(diff, rel)
-			query = fmt.Sprintf(`UPDATE %v SET %s WHERE %v='%s'`, table, set, condition, idVal)
-		case json.Number:
-			set := buildSetClause(diff, rel)
-			query = fmt.Sprintf(`UPDATE %v SET %s WHERE %v=%v`, table, set, condition, idVal)
-		}
-	default:
-		diff, _, err := findDiffsForQuery(original, new, condition, ignoreEmpty)
-		if err != nil {
-			return "", err
-		}
-		set := buildSetClause(diff, rel)
-		query = fmt.Sprintf(`UPDATE "%s" SET %s %v`, table, set, condition)
 	}
-	return query, nil
+	diff, _, idVal, err := findDiffsForQuery(original, new, condition, ignoreEmpty, opts)
+	if err != nil {
+		return "", err
+	}
+	return buildQuery(table, condition, idVal, buildSetClause(diff, rel)), nil
+}
+
+// PatchWithRollbackQuery works as PatchWithQuery and also returns the rollback query that restores the original values of the updated attributes.
+// Both queries share the same condition. Original null values are restored as NULL.
+func PatchWithRollbackQuery(original, new []byte, table, condition string, ignoreEmpty bool, rel map[string]string, optFuncs ...Option) (query, rollback string, err error) {
+	opts := newOptions(optFuncs)
+
+	if condition == "" {
+		return "", "", ErrNoCondition
+	}
+	diff, old, idVal, err := findDiffsForQuery(original, new, condition, ignoreEmpty, opts)
+	if err != nil {
+		return "", "", err
+	}
+	query = buildQuery(table, condition, idVal, buildSetClause(diff, rel))
+	rollback = buildQuery(table, condition, idVal, buildSetClause(old, rel))
+	return query, rollback, nil
 }
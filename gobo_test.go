@@ -1,7 +1,9 @@
 package gobo
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 	"testing"
 
@@ -38,7 +40,7 @@ func TestJSONDiff(t *testing.T) {
 		newData := `{"name":"Jane", "lastname":"Doe"}`
 		diff, err := JSONDiff([]byte(dbRec), []byte(newData))
 		assert.Equal(t, map[string]interface{}(nil), diff)
-		assert.Equal(t, err, ErrKeyConflict)
+		assert.ErrorIs(t, err, ErrKeyConflict)
 	})
 	t.Run("detect differences in complex json", func(t *testing.T) {
 		dbRec := `{"name":"John", "last_name":"Doe", "meta":{"country":"Argentina", "age":45}}`
@@ -292,3 +294,433 @@ func TestPatchWithQuery(t *testing.T) {
 		assert.Equal(t, `UPDATE public.project SET "name"='resources manager' WHERE id=1014336373145370625`, query)
 	})
 }
+
+func TestPathOptions(t *testing.T) {
+	dbRec := `{"name":"John", "tags":["go", "sql"], "history":["created"], "coordinates":[1, 2], "meta":{"country":"Argentina", "updated_at":"2024-01-01"}}`
+	newData := `{"name":"Jane", "tags":["go", "json"], "history":["updated"], "coordinates":[3, 4], "meta":{"country":"Brazil", "updated_at":"2024-02-02"}}`
+	t.Run("slice strategy per path", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData),
+			UsePathOptions("/history", UseAddNewSlice()),
+			UsePathOptions("/coordinates", UseReplaceSlice()),
+			UsePathOptions("/meta/updated_at", UseIgnore()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"name":        "Jane",
+			"tags":        []interface{}{"go", "sql", "json"},
+			"history":     []interface{}{"created", "updated"},
+			"coordinates": []interface{}{3.0, 4.0},
+			"country":     "Brazil",
+		}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("path options override global ones", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UseReplaceSlice(), UsePathOptions("/tags", UseMergeSlice()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{"go", "sql", "json"}, diff["tags"])
+		assert.Equal(t, []interface{}{"updated"}, diff["history"])
+	})
+	t.Run("most specific pattern wins", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData),
+			UsePathOptions("/coordinates", UseAddNewSlice()),
+			UsePathOptions("/*", UseReplaceSlice()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{1.0, 2.0, 3.0, 4.0}, diff["coordinates"])
+		assert.Equal(t, []interface{}{"go", "json"}, diff["tags"])
+	})
+	t.Run("ignore subtree with globs", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UsePathOptions("/**/updated_at", UseIgnore()), UsePathOptions("/meta", UseIgnore()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotContains(t, diff, "country")
+		assert.NotContains(t, diff, "updated_at")
+		assert.Equal(t, "Jane", diff["name"])
+	})
+	t.Run("escaped keys", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(`{"a/b":"x", "c":"y"}`), []byte(`{"a/b":"z", "c":"w"}`), UsePathOptions("/a~1b", UseIgnore()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"c": "w"}, diff)
+	})
+}
+
+func TestPathFilters(t *testing.T) {
+	dbRec := `{"name":"John", "email":"john@mail.com", "updated_at":"2024-01-01", "_links":{"self":"/users/1"}, "meta":{"country":"Argentina", "city":"Rosario"}}`
+	newData := `{"name":"Jane", "email":"jane@mail.com", "updated_at":"2024-02-02", "_links":{"self":"/users/2"}, "meta":{"country":"Brazil", "city":"Recife"}}`
+	t.Run("exclude volatile fields", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UseExcludePaths("/updated_at", "/_links", "/meta/city"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"name": "Jane", "email": "jane@mail.com", "country": "Brazil"}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("include allowlist", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UseIncludePaths("/name", "/meta/country"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane", "country": "Brazil"}, diff)
+	})
+	t.Run("exclusions win over inclusions", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UseIncludePaths("/meta"), UseExcludePaths("/meta/city"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"country": "Brazil"}, diff)
+	})
+	t.Run("include rules over array items", func(t *testing.T) {
+		original := `{"items":[{"name":"a", "qty":1}, {"name":"b", "qty":1}]}`
+		new := `{"items":[{"name":"a", "qty":2}, {"name":"c", "qty":1}]}`
+		diff, err := JSONDiff([]byte(original), []byte(new), UseIncludePaths("/items/*/name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"items": map[string]interface{}{"name": "c"}}, diff)
+	})
+	t.Run("nothing left to compare", func(t *testing.T) {
+		_, err := JSONDiff([]byte(dbRec), []byte(newData), UseIncludePaths("/missing"))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("filters in query", func(t *testing.T) {
+		db := `{"id":1234, "name": "Gonzalo", "age": 19, "etag": "abc"}`
+		new := `{"name": "Gonza", "age": 20, "etag": "def"}`
+		query, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil, UseExcludePaths("/etag"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET age=20, name='Gonza' WHERE id=1234`, query)
+		query, err = PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil, UseIncludePaths("/name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET name='Gonza' WHERE id=1234`, query)
+	})
+}
+
+func TestComparators(t *testing.T) {
+	t.Run("equal numbers are not reported", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(`{"name":"John", "age":32}`), []byte(`{"name":"Jane", "age":32}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane"}, diff)
+	})
+	t.Run("built-in comparators", func(t *testing.T) {
+		dbRec := `{"email":"John@Mail.com", "created_at":"2024-01-01T10:00:00Z", "score":1.5, "ref":"6ba7b810-9dad-11d1-80b4-00c04fd430c8", "name":"John"}`
+		newData := `{"email":"john@mail.com", "created_at":"2024-01-01T07:00:00-03:00", "score":1.5001, "ref":"6BA7B810-9DAD-11D1-80B4-00C04FD430C8", "name":"Jane"}`
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData),
+			UsePathOptions("/email", UseComparator(CaseInsensitiveComparator())),
+			UseComparator(TimeComparator()),
+			UseComparator(EpsilonComparator(0.001)),
+			UseComparator(UUIDComparator()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane"}, diff)
+	})
+	t.Run("custom comparator in slices", func(t *testing.T) {
+		dbRec := `{"tags":["Go", "SQL"]}`
+		newData := `{"tags":["go", "sql", "json"]}`
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData), UseComparator(CaseInsensitiveComparator()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{"Go", "SQL", "json"}, diff["tags"])
+	})
+	t.Run("comparators in query", func(t *testing.T) {
+		db := `{"id":1234, "email":"John@Mail.com", "age": 19, "name":"John"}`
+		new := `{"email":"john@mail.com", "age": 19, "name":"Jane"}`
+		query, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", false, nil, UseComparator(CaseInsensitiveComparator()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET name='Jane' WHERE id=1234`, query)
+	})
+	t.Run("comparator func", func(t *testing.T) {
+		trimmed := ComparatorFunc(func(original, new interface{}) (bool, bool) {
+			origStr, ok := original.(string)
+			if !ok {
+				return false, false
+			}
+			newStr, ok := new.(string)
+			return strings.TrimSpace(origStr) == strings.TrimSpace(newStr), ok
+		})
+		_, err := JSONDiff([]byte(`{"name":"John"}`), []byte(`{"name":" John "}`), UseComparator(trimmed))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
+
+func TestJSONDiffValue(t *testing.T) {
+	t.Run("objects", func(t *testing.T) {
+		diff, err := JSONDiffValue([]byte(`{"name":"John", "age":32}`), []byte(`{"name":"Jane", "age":32}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane"}, diff)
+	})
+	t.Run("root arrays", func(t *testing.T) {
+		dbRec := `["Argentina", "Brazil"]`
+		newData := `["Argentina", "Canada"]`
+		diff, err := JSONDiffValue([]byte(dbRec), []byte(newData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{"Argentina", "Brazil", "Canada"}, diff)
+		diff, err = JSONDiffValue([]byte(dbRec), []byte(newData), UseReplaceSlice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{"Argentina", "Canada"}, diff)
+	})
+	t.Run("root arrays of records", func(t *testing.T) {
+		dbRec := `[{"id":1, "name":"John"}, {"id":2, "name":"Jim"}]`
+		newData := `[{"id":1, "name":"John"}, {"id":2, "name":"Jane"}]`
+		diff, err := JSONDiffValue([]byte(dbRec), []byte(newData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane"}, diff)
+		diff, err = JSONDiffValue([]byte(dbRec), []byte(newData), UsePathOptions("/1", UseIgnore()))
+		assert.Equal(t, ErrNoDiff, err)
+		assert.Nil(t, diff)
+	})
+	t.Run("root scalars", func(t *testing.T) {
+		diff, err := JSONDiffValue([]byte(`"John"`), []byte(`"Jane"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Jane", diff)
+		_, err = JSONDiffValue([]byte(`32`), []byte(`32`))
+		assert.Equal(t, ErrNoDiff, err)
+		diff, err = JSONDiffValue([]byte(`[1]`), []byte(`null`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Nil(t, diff)
+	})
+}
+
+func TestTypeChanges(t *testing.T) {
+	dbRec := `{"name":"John", "meta":{"country":"Argentina"}, "tags":["a", "b"], "nickname":null, "phone":"123"}`
+	newData := `{"name":"John", "meta":"none", "tags":null, "nickname":null, "phone":["123", "456"]}`
+	t.Run("reported without panics", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(dbRec), []byte(newData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"meta": "none", "tags": nil, "phone": []interface{}{"123", "456"}}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("nested and slice items", func(t *testing.T) {
+		diff, err := JSONDiff([]byte(`{"meta":{"tags":["a"], "age":null}, "items":[1, null]}`), []byte(`{"meta":{"tags":"a", "age":30}, "items":[1, {"a":1}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{"tags": "a", "age": 30.0, "items": []interface{}{1.0, nil, map[string]interface{}{"a": 1.0}}}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("reject type changes", func(t *testing.T) {
+		_, err := JSONDiff([]byte(dbRec), []byte(newData), UseRejectTypeChange())
+		assert.ErrorIs(t, err, ErrTypeChanged)
+		_, err = JSONDiff([]byte(`{"tags":["a"]}`), []byte(`{"tags":null}`), UseRejectTypeChange())
+		assert.NoError(t, err)
+		_, err = PatchWithQuery([]byte(`{"id":1, "age":30}`), []byte(`{"age":"30"}`), "users", "id", false, nil, UseRejectTypeChange())
+		assert.ErrorIs(t, err, ErrTypeChanged)
+	})
+	t.Run("type-changed changes", func(t *testing.T) {
+		changes, err := Changes([]byte(dbRec), []byte(newData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/meta", Kind: ChangeTypeChanged, Old: map[string]interface{}{"country": "Argentina"}, New: "none"},
+			{Path: "/phone", Kind: ChangeTypeChanged, Old: "123", New: []interface{}{"123", "456"}},
+			{Path: "/tags", Kind: ChangeTypeChanged, Old: []interface{}{"a", "b"}, New: nil},
+		}
+		assert.Equal(t, expected, changes)
+		_, err = Changes([]byte(dbRec), []byte(newData), UsePathOptions("/meta", UseRejectTypeChange()))
+		assert.ErrorIs(t, err, ErrTypeChanged)
+	})
+}
+
+func TestQueryErrors(t *testing.T) {
+	t.Run("unsupported type", func(t *testing.T) {
+		db := `{"id":1234, "name": "Gonzalo", "meta": {"country": "Argentina"}}`
+		new := `{"name": "Gonza", "meta": {"country": "Brazil"}}`
+		_, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		query, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil, UseExcludePaths("/meta"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET name='Gonza' WHERE id=1234`, query)
+	})
+	t.Run("invalid number", func(t *testing.T) {
+		_, err := PatchWithQuery([]byte(`{"id":1, "size":1}`), []byte(`{"size":1e400}`), "users", "id", true, nil)
+		assert.ErrorIs(t, err, ErrInvalidNumber)
+	})
+	t.Run("null values", func(t *testing.T) {
+		db := `{"id":1234, "name": "Gonzalo", "nickname": "gonza"}`
+		new := `{"name": "Gonza", "nickname": null}`
+		query, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET name='Gonza', nickname=NULL WHERE id=1234`, query)
+		query, err = PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET name='Gonza' WHERE id=1234`, query)
+	})
+	t.Run("diagnostics logger", func(t *testing.T) {
+		var buf strings.Builder
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		_, err := JSONDiff([]byte(`{"name":"John", "last_name":"Doe"}`), []byte(`{"name":"Jane", "lastname":"Doe"}`), UseLogger(logger))
+		assert.ErrorIs(t, err, ErrKeyConflict)
+		assert.Contains(t, buf.String(), "key conflict")
+	})
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("path error", func(t *testing.T) {
+		_, err := JSONDiff([]byte(`{"name":"John", "last_name":"Doe"}`), []byte(`{"name":"Jane", "lastname":"Doe"}`))
+		var pathErr *PathError
+		if assert.ErrorAs(t, err, &pathErr) {
+			assert.Equal(t, "/lastname", pathErr.Path)
+			assert.Equal(t, "Doe", pathErr.Old)
+			assert.Equal(t, "Doe", pathErr.New)
+		}
+		assert.ErrorIs(t, err, ErrKeyConflict)
+		assert.Equal(t, "keys with equal values have different names: /last_name at /lastname", err.Error())
+		for i := 0; i < 20; i++ {
+			_, err = PatchWithQuery([]byte(`{"id":1, "a":"x", "b":"x", "c":"y"}`), []byte(`{"id":1, "e":"y", "d":"x"}`), "t", "id", false, nil)
+			assert.EqualError(t, err, "keys with equal values have different names: /a at /d")
+		}
+	})
+	t.Run("parse error", func(t *testing.T) {
+		_, err := JSONDiff([]byte(`{"name":"John",}`), []byte(`{"name":"Jane"}`))
+		var parseErr *ParseError
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, "original", parseErr.Document)
+			assert.Equal(t, int64(16), parseErr.Offset)
+		}
+		assert.Contains(t, err.Error(), "original json-encoded parse failed")
+	})
+	t.Run("collect every error", func(t *testing.T) {
+		db := `{"id":1, "age":30, "name":"John", "meta":{"country":"Argentina"}}`
+		new := `{"age":"30", "name":"Jane", "meta":{"country":"Brazil"}}`
+		_, err := PatchWithQuery([]byte(db), []byte(new), "users", "id", true, nil, UseRejectTypeChange(), UseCollectErrors())
+		assert.ErrorIs(t, err, ErrTypeChanged)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		var paths []string
+		for _, err := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var pathErr *PathError
+			if assert.ErrorAs(t, err, &pathErr) {
+				paths = append(paths, pathErr.Path)
+			}
+		}
+		assert.ElementsMatch(t, []string{"/age", "/meta"}, paths)
+	})
+	t.Run("collect type changes", func(t *testing.T) {
+		_, err := Changes([]byte(`{"a":1, "b":"x", "c":[1]}`), []byte(`{"a":"1", "b":true, "c":[1]}`), UseRejectTypeChange(), UseCollectErrors())
+		assert.Equal(t, "value changed its type: number to string at /a\nvalue changed its type: string to boolean at /b", err.Error())
+	})
+}
+
+// wideDocuments returns two flat documents with n keys where one of every ten values changed.
+func wideDocuments(n int) (original, new []byte) {
+	orig := make(map[string]interface{}, n)
+	upd := make(map[string]interface{}, n)
+	for i := range n {
+		key := fmt.Sprintf("column_%d", i)
+		orig[key] = fmt.Sprintf("value %d", i)
+		upd[key] = fmt.Sprintf("value %d", i)
+		if i%10 == 0 {
+			upd[key] = fmt.Sprintf("updated %d", i)
+		}
+	}
+	original, _ = json.Marshal(orig)
+	new, _ = json.Marshal(upd)
+	return original, new
+}
+
+// deepDocuments returns two documents with n nested objects of width keys and an array of n objects.
+func deepDocuments(n, width int) (original, new []byte) {
+	build := func(prefix string) map[string]interface{} {
+		doc := make(map[string]interface{}, n+1)
+		var items []interface{}
+		for i := range n {
+			nested := make(map[string]interface{}, width)
+			for j := range width {
+				nested[fmt.Sprintf("field_%d_%d", i, j)] = fmt.Sprintf("%s %d %d", prefix, i, j%2)
+			}
+			doc[fmt.Sprintf("object_%d", i)] = nested
+			items = append(items, map[string]interface{}{"id": float64(i), "nested": nested})
+		}
+		doc["items"] = items
+		return doc
+	}
+	original, _ = json.Marshal(build("value"))
+	new, _ = json.Marshal(build("updated"))
+	return original, new
+}
+
+func BenchmarkJSONDiffWide(b *testing.B) {
+	original, new := wideDocuments(500)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := JSONDiff(original, new); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONDiffDeep(b *testing.B) {
+	original, new := deepDocuments(100, 20)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := JSONDiff(original, new); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPatchWithQueryWide(b *testing.B) {
+	original, new := wideDocuments(500)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := PatchWithQuery(original, new, "users", "id", true, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkIterateMapsDeep measures the engine alone, on documents decoded once, to guard its allocations.
+func BenchmarkIterateMapsDeep(b *testing.B) {
+	originalBytes, newBytes := deepDocuments(100, 20)
+	var original, new map[string]interface{}
+	if err := json.Unmarshal(originalBytes, &original); err != nil {
+		b.Fatal(err)
+	}
+	if err := json.Unmarshal(newBytes, &new); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := iterateMaps(original, new, Options{}, ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gobo
+
+import "fmt"
+
+// PatchWithGraphQLInput returns the input object of a GraphQL mutation with only the values that changed from the original
+// document to the new one, keeping their nesting. Removed values are null, as GraphQL clears fields given null.
+// Lists can't be partially updated, so a changed array is given whole, as JSONDiff returns it with the slice options.
+//
+// rel maps json keys to input field names: a JSON Pointer such as "/address/zip" renames that value and a plain key
+// such as "zip_code" renames every key with that name. Numbers are int64 or float64.
+func PatchWithGraphQLInput(original, new []byte, rel map[string]string, optFuncs ...Option) (map[string]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	var originalMap, newMap map[string]interface{}
+	if err := decodeDocument("original", original, &originalMap, true, opts); err != nil {
+		return nil, err
+	}
+	if err := decodeDocument("new", new, &newMap, true, opts); err != nil {
+		return nil, err
+	}
+	u := mergePatch{rel: rel, numbers: true, patch: make(map[string]interface{})}
+	err := diffUpdate(originalMap, newMap, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.patch) == 0 {
+		return nil, ErrNoDiff
+	}
+	return u.patch, nil
+}
+
+// PatchWithGraphQLMutation returns a mutation document calling the mutation with the input found by PatchWithGraphQLInput
+// as the $input variable of the inputType, and the variables to send with it. The mutation selects __typename,
+// so it works with any object result.
+func PatchWithGraphQLMutation(original, new []byte, mutation, inputType string, rel map[string]string, optFuncs ...Option) (document string, variables map[string]interface{}, err error) {
+	for _, name := range []string{mutation, inputType} {
+		if !identifier.MatchString(name) {
+			return "", nil, fmt.Errorf("%w: graphql name %q", ErrUnsupportedType, name)
+		}
+	}
+	input, err := PatchWithGraphQLInput(original, new, rel, optFuncs...)
+	if err != nil {
+		return "", nil, err
+	}
+	document = fmt.Sprintf("mutation($input: %s!) {\n  %s(input: $input) {\n    __typename\n  }\n}", inputType, mutation)
+	return document, map[string]interface{}{"input": input}, nil
+}
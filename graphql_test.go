@@ -0,0 +1,51 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL(t *testing.T) {
+	original := `{"id":1, "first_name":"John", "age":32, "phone":"123", "address":{"city":"Rosario", "zip_code":"2000"}, "tags":["a"]}`
+	new := `{"id":1, "first_name":"Jane", "age":32, "address":{"city":"Rosario", "zip_code":"2001", "street":{"zip_code":"x"}}, "tags":["a", "b"]}`
+	rel := map[string]string{"first_name": "firstName", "zip_code": "zipCode", "/address": "location"}
+	t.Run("input", func(t *testing.T) {
+		input, err := PatchWithGraphQLInput([]byte(original), []byte(new), rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"firstName": "Jane",
+			"phone":     nil,
+			"location": map[string]interface{}{
+				"zipCode": "2001",
+				"street":  map[string]interface{}{"zipCode": "x"},
+			},
+			"tags": []interface{}{"a", "b"},
+		}
+		assert.Equal(t, expected, input)
+
+		input, err = PatchWithGraphQLInput([]byte(`{"id":1, "name":"a", "tags":["a", "b"]}`), []byte(`{"id":1, "name":"b", "tags":["a"]}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "b"}, input)
+		_, err = PatchWithGraphQLInput([]byte(`{"id":1, "tags":["a", "b"]}`), []byte(`{"id":1, "tags":["a"]}`), nil)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("mutation", func(t *testing.T) {
+		document, variables, err := PatchWithGraphQLMutation([]byte(original), []byte(new), "updateUser", "UserInput", nil,
+			UseIncludePaths("/first_name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "mutation($input: UserInput!) {\n  updateUser(input: $input) {\n    __typename\n  }\n}", document)
+		assert.Equal(t, map[string]interface{}{"input": map[string]interface{}{"first_name": "Jane"}}, variables)
+
+		_, _, err = PatchWithGraphQLMutation([]byte(original), []byte(new), "update user", "UserInput", nil)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		_, _, err = PatchWithGraphQLMutation([]byte(original), []byte(original), "updateUser", "UserInput", nil)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
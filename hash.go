@@ -0,0 +1,191 @@
+package gobo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Document is a decoded json document with a content hash for each of its objects and arrays.
+// Diffing a Document skips the branches whose hash is equal on both sides without walking them,
+// so a cached base document can be compared against many versions cheaply. A Document is safe for concurrent diffs.
+type Document struct {
+	value  interface{}
+	hashes hashIndex
+}
+
+// ParseDocument decodes the json document and hashes its subtrees once. Only the limits of the options are used.
+func ParseDocument(data []byte, optFuncs ...Option) (*Document, error) {
+	opts := newOptions(optFuncs)
+	var value interface{}
+	if err := decodeDocument("original", data, &value, false, opts); err != nil {
+		return nil, err
+	}
+	hashes := make(hashIndex)
+	hashValue(value, "", hashes)
+	return &Document{value: value, hashes: hashes}, nil
+}
+
+// JSONDiff works as the JSONDiff function using the document as the original one. Its root must be an object.
+func (d *Document) JSONDiff(new []byte, optFuncs ...Option) (map[string]interface{}, error) {
+	original, ok := d.value.(map[string]interface{})
+	if !ok {
+		return nil, &ParseError{Document: "original", Format: "json", Err: fmt.Errorf("root is %s, not an object", jsonKind(d.value))}
+	}
+	opts, newVal, err := d.decode(new, optFuncs)
+	if err != nil {
+		return nil, err
+	}
+	newMap, ok := newVal.(map[string]interface{})
+	if !ok {
+		return nil, &ParseError{Document: "new", Format: "json", Err: fmt.Errorf("root is %s, not an object", jsonKind(newVal))}
+	}
+	diff, err := iterateMaps(original, newMap, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Changes works as the Changes function using the document as the original one.
+func (d *Document) Changes(new []byte, optFuncs ...Option) ([]Change, error) {
+	opts, newVal, err := d.decode(new, optFuncs)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffValues(d.value, newVal, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, ErrNoDiff
+	}
+	return changes, nil
+}
+
+// decode decodes and hashes the new document, returning the options that compare both hashes.
+func (d *Document) decode(new []byte, optFuncs []Option) (Options, interface{}, error) {
+	opts := newOptions(optFuncs)
+	var newVal interface{}
+	if err := decodeDocument("new", new, &newVal, false, opts); err != nil {
+		return opts, nil, err
+	}
+	newHashes := make(hashIndex)
+	hashValue(newVal, "", newHashes)
+	opts.hashes = &hashPair{original: d.hashes, new: newHashes}
+	return opts, newVal, nil
+}
+
+// subtreeHash is a SHA-256 digest truncated to 128 bits. A cryptographic hash keeps crafted documents
+// from matching the hash of a cached branch to hide their changes.
+type subtreeHash [16]byte
+
+// hashIndex maps the path of each object and array to the hash of its content.
+type hashIndex map[string]subtreeHash
+
+type hashPair struct {
+	original, new hashIndex
+}
+
+// unchanged reports whether the object or array at the path has the same content in both documents.
+// Values with the same content are considered equal by every comparator.
+func (o Options) unchanged(path string) bool {
+	if o.hashes == nil {
+		return false
+	}
+	origHash, found := o.hashes.original[path]
+	if !found {
+		return false
+	}
+	newHash, found := o.hashes.new[path]
+	return found && origHash == newHash
+}
+
+// hashValue returns the hash of the value, built from the hashes of its children, and records the ones of objects and arrays.
+// Object keys are hashed sorted, so the order of the keys doesn't change the hash.
+func hashValue(v interface{}, path string, index hashIndex) subtreeHash {
+	h := sha256.New()
+	writeHash(h, v, path, index)
+	var full [sha256.Size]byte
+	var sum subtreeHash
+	copy(sum[:], h.Sum(full[:0]))
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		index[path] = sum
+	}
+	return sum
+}
+
+// writeHash writes the value to the hasher. Scalars are written as they are, objects and arrays are hashed on their own
+// so their hash is recorded, and their hash is written after a tag byte to keep the encoding unambiguous.
+func writeHash(h hash.Hash, v interface{}, path string, index hashIndex) {
+	var buf [8]byte
+	writeString := func(s string) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(len(s)))
+		h.Write(buf[:])
+		io.WriteString(h, s)
+	}
+	writeChild := func(child interface{}, seg string) {
+		switch child.(type) {
+		case map[string]interface{}, []interface{}:
+			sum := hashValue(child, joinPath(path, seg), index)
+			h.Write([]byte{'h'})
+			h.Write(sum[:])
+		default:
+			writeHash(h, child, "", index)
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		h.Write([]byte{'n'})
+	case bool:
+		if v {
+			h.Write([]byte{'t'})
+		} else {
+			h.Write([]byte{'f'})
+		}
+	case float64:
+		if v == 0 {
+			// negative zero is equal to zero
+			v = 0
+		}
+		h.Write([]byte{'d'})
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		h.Write(buf[:])
+	case json.Number:
+		h.Write([]byte{'N'})
+		writeString(v.String())
+	case string:
+		h.Write([]byte{'s'})
+		writeString(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		h.Write([]byte{'o'})
+		binary.LittleEndian.PutUint64(buf[:], uint64(len(keys)))
+		h.Write(buf[:])
+		for _, k := range keys {
+			writeString(k)
+			writeChild(v[k], k)
+		}
+	case []interface{}:
+		h.Write([]byte{'a'})
+		binary.LittleEndian.PutUint64(buf[:], uint64(len(v)))
+		h.Write(buf[:])
+		for i, item := range v {
+			writeChild(item, strconv.Itoa(i))
+		}
+	default:
+		h.Write([]byte{'x'})
+		writeString(fmt.Sprintf("%T:%v", v, v))
+	}
+}
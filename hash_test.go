@@ -0,0 +1,111 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocument(t *testing.T) {
+	original := `{"name":"John", "meta":{"country":"Argentina", "tags":["a", "b"]}, "history":[{"id":1}, {"id":2}], "phone":"123"}`
+	new := `{"name":"Jane", "meta":{"tags":["a", "b"], "country":"Argentina"}, "history":[{"id":1}, {"id":3}], "email":"jane@mail.com"}`
+	doc, err := ParseDocument([]byte(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("same differences as the functions", func(t *testing.T) {
+		expected, err := JSONDiff([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := doc.JSONDiff([]byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, diff)
+
+		expectedChanges, err := Changes([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := doc.Changes([]byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expectedChanges, changes)
+	})
+	t.Run("unchanged branches are skipped", func(t *testing.T) {
+		var compared []interface{}
+		counter := ComparatorFunc(func(original, new interface{}) (bool, bool) {
+			compared = append(compared, new)
+			return false, false
+		})
+		_, err := doc.Changes([]byte(new), UseComparator(counter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.ElementsMatch(t, []interface{}{"Jane", float64(3)}, compared)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		_, err := doc.Changes([]byte(original))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("root must be an object", func(t *testing.T) {
+		arrayDoc, err := ParseDocument([]byte(`[1, 2]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = arrayDoc.JSONDiff([]byte(`{"a":1}`))
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr)
+		assert.EqualError(t, err, "original json-encoded parse failed: root is array, not an object")
+		changes, err := arrayDoc.Changes([]byte(`[1, 3]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/1", Kind: ChangeModified, Old: float64(2), New: float64(3)}}, changes)
+	})
+}
+
+// cachedDocuments returns two documents with n nested objects of width keys where only one value of the first object changed,
+// as when a cached document is compared against a new version.
+func cachedDocuments(n, width int) (original, new []byte) {
+	doc := make(map[string]interface{}, n)
+	for i := range n {
+		nested := make(map[string]interface{}, width)
+		for j := range width {
+			nested[fmt.Sprintf("field_%d", j)] = fmt.Sprintf("value %d %d", i, j)
+		}
+		doc[fmt.Sprintf("object_%d", i)] = nested
+	}
+	original, _ = json.Marshal(doc)
+	doc["object_0"].(map[string]interface{})["field_0"] = "updated"
+	new, _ = json.Marshal(doc)
+	return original, new
+}
+
+func BenchmarkDocumentChangesCached(b *testing.B) {
+	original, new := cachedDocuments(1000, 20)
+	doc, err := ParseDocument(original)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := doc.Changes(new); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkChangesCached(b *testing.B) {
+	original, new := cachedDocuments(1000, 20)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := Changes(original, new); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
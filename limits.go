@@ -0,0 +1,94 @@
+package gobo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// JSONDiffContext works as JSONDiff but stops with the context error when ctx is canceled or its deadline is exceeded.
+// Combine it with UseMaxBytes, UseMaxDepth, UseMaxKeys and UseMaxArrayLength to bound the work done for untrusted documents.
+func JSONDiffContext(ctx context.Context, original, new []byte, optFuncs ...Option) (diff map[string]interface{}, err error) {
+	return JSONDiff(original, new, append(optFuncs, func(opts *Options) {
+		opts.ctx = ctx
+	})...)
+}
+
+// decodeDocument decodes the json document into v enforcing the size, depth, keys and array length limits of the options.
+// With useNumber, numbers are decoded as json.Number.
+func decodeDocument(name string, data []byte, v interface{}, useNumber bool, opts Options) (err error) {
+	if opts.MaxBytes > 0 && len(data) > opts.MaxBytes {
+		return &ParseError{Document: name, Format: opts.encoding(), Err: fmt.Errorf("%w: %d bytes of %d", ErrMaxBytes, len(data), opts.MaxBytes)}
+	}
+	if err = opts.canceled(); err != nil {
+		return err
+	}
+	switch {
+	case opts.decoder != nil:
+		if err = decodeWith(opts.decoder, data, v, useNumber); err != nil {
+			return &ParseError{Document: name, Format: opts.encoding(), Err: err}
+		}
+	case useNumber:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(v)
+	default:
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return parseError(name, err)
+	}
+	if opts.MaxDepth > 0 || opts.MaxKeys > 0 || opts.MaxArrayLength > 0 {
+		return checkLimits(reflect.ValueOf(v).Elem().Interface(), opts, "", 0)
+	}
+	return nil
+}
+
+// checkLimits walks the decoded value and fails at the first object or array exceeding the limits.
+func checkLimits(v interface{}, opts Options, path string, depth int) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := checkContainer(opts, path, depth+1, len(v), opts.MaxKeys, ErrMaxKeys); err != nil {
+			return err
+		}
+		for k, val := range v {
+			if err := checkLimits(val, opts, joinPath(path, k), depth+1); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if err := checkContainer(opts, path, depth+1, len(v), opts.MaxArrayLength, ErrMaxArrayLength); err != nil {
+			return err
+		}
+		for i, val := range v {
+			if err := checkLimits(val, opts, joinPath(path, strconv.Itoa(i)), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkContainer(opts Options, path string, depth, size, maxSize int, sizeErr error) error {
+	if err := opts.canceled(); err != nil {
+		return err
+	}
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return &PathError{Path: path, Reason: fmt.Errorf("%w: %d", ErrMaxDepth, opts.MaxDepth)}
+	}
+	if maxSize > 0 && size > maxSize {
+		return &PathError{Path: path, Reason: fmt.Errorf("%w: %d of %d", sizeErr, size, maxSize)}
+	}
+	return nil
+}
+
+// canceled returns the error of the context given to JSONDiffContext, if any.
+func (o Options) canceled() error {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Err()
+}
@@ -0,0 +1,75 @@
+package gobo
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	dbRec := `{"name":"John", "meta":{"tags":["a", "b", "c"], "address":{"country":"Argentina"}}}`
+	newData := `{"name":"Jane", "meta":{"tags":["a", "b", "d"], "address":{"country":"Brazil"}}}`
+	t.Run("within limits", func(t *testing.T) {
+		_, err := JSONDiff([]byte(dbRec), []byte(newData), UseMaxBytes(1024), UseMaxDepth(3), UseMaxKeys(2), UseMaxArrayLength(3))
+		assert.NoError(t, err)
+	})
+	t.Run("max bytes", func(t *testing.T) {
+		_, err := JSONDiff([]byte(dbRec), []byte(newData+strings.Repeat(" ", 100)), UseMaxBytes(len(dbRec)))
+		assert.ErrorIs(t, err, ErrMaxBytes)
+		var parseErr *ParseError
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, "new", parseErr.Document)
+		}
+	})
+	t.Run("max depth", func(t *testing.T) {
+		_, err := JSONDiff([]byte(dbRec), []byte(newData), UseMaxDepth(2))
+		assert.ErrorIs(t, err, ErrMaxDepth)
+		var pathErr *PathError
+		if assert.ErrorAs(t, err, &pathErr) {
+			assert.Contains(t, []string{"/meta/tags", "/meta/address"}, pathErr.Path)
+		}
+	})
+	t.Run("max keys", func(t *testing.T) {
+		_, err := PatchWithQuery([]byte(`{"id":1, "a":"x", "b":"y"}`), []byte(`{"a":"z"}`), "t", "id", true, nil, UseMaxKeys(2))
+		assert.ErrorIs(t, err, ErrMaxKeys)
+	})
+	t.Run("max array length", func(t *testing.T) {
+		_, err := Changes([]byte(dbRec), []byte(newData), UseMaxArrayLength(2))
+		assert.ErrorIs(t, err, ErrMaxArrayLength)
+		assert.Equal(t, "array exceeds the maximum length: 3 of 2 at /meta/tags", err.Error())
+	})
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := JSONDiffContext(ctx, []byte(dbRec), []byte(newData))
+		assert.ErrorIs(t, err, context.Canceled)
+		diff, err := JSONDiffContext(context.Background(), []byte(dbRec), []byte(newData))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "Jane", diff["name"])
+	})
+	t.Run("deadline during a large array diff", func(t *testing.T) {
+		original, new := make([]int, 20000), make([]int, 20001)
+		for i := range new {
+			new[i] = i + 1
+			if i < len(original) {
+				original[i] = -i
+			}
+		}
+		originalDoc, _ := json.Marshal(map[string]interface{}{"items": original})
+		newDoc, _ := json.Marshal(map[string]interface{}{"items": new})
+		for _, parallelism := range []int{1, 4} {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			start := time.Now()
+			_, err := JSONDiffContext(ctx, originalDoc, newDoc, UseParallelism(parallelism))
+			cancel()
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Less(t, time.Since(start), time.Second)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Conflict is reported when both sides of a merge changed the same value, or one of them changed a value
+// inside another one changed by the other side, in different ways.
+type Conflict struct {
+	Path   string
+	Ours   Change
+	Theirs Change
+}
+
+// ConflictResolver picks the change applied for a conflict. It can return one of the conflicting changes
+// or a new one, for example to combine both values.
+type ConflictResolver func(c Conflict) (Change, error)
+
+// ResolveOurs keeps our change in every conflict.
+func ResolveOurs(c Conflict) (Change, error) {
+	return c.Ours, nil
+}
+
+// ResolveTheirs keeps their change in every conflict.
+func ResolveTheirs(c Conflict) (Change, error) {
+	return c.Theirs, nil
+}
+
+// ResolveFail aborts the merge with a *PathError wrapping ErrMergeConflict, where Old holds our value and New theirs.
+// It's the default resolver.
+func ResolveFail(c Conflict) (Change, error) {
+	return Change{}, &PathError{Path: c.Path, Old: c.Ours.New, New: c.Theirs.New, Reason: ErrMergeConflict}
+}
+
+// Merge performs a three-way merge of the json documents. It computes the changes from base to ours and from base to theirs,
+// applies the ones that don't overlap and resolves the rest with the resolver given with UseConflictResolver.
+//
+// It returns the merged json document and every conflict found, even the resolved ones. With the default resolver, ResolveFail,
+// the merged document is nil and the error wraps ErrMergeConflict.
+// Options such as UseExcludePaths or UseComparator are applied to both diffs, see Changes.
+func Merge(base, ours, theirs []byte, optFuncs ...Option) (merged []byte, conflicts []Conflict, err error) {
+	opts := newOptions(optFuncs)
+	resolve := opts.resolver
+	if resolve == nil {
+		resolve = ResolveFail
+	}
+
+	var baseVal, oursVal, theirsVal interface{}
+	err = decodeDocument("base", base, &baseVal, true, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = decodeDocument("ours", ours, &oursVal, true, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = decodeDocument("theirs", theirs, &theirsVal, true, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	oursChanges, err := diffValues(baseVal, oursVal, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, nil, err
+	}
+	theirsChanges, err := diffValues(baseVal, theirsVal, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, nil, err
+	}
+
+	var changes []Change
+	conflicted := make(map[string]bool)
+	for _, ourChange := range oursChanges {
+		for _, theirChange := range theirsChanges {
+			overlap := ourChange.Path == theirChange.Path || isAncestor(ourChange.Path, theirChange.Path) || isAncestor(theirChange.Path, ourChange.Path)
+			if !overlap {
+				continue
+			}
+			conflicted["o"+ourChange.Path] = true
+			conflicted["t"+theirChange.Path] = true
+			if ourChange.Path == theirChange.Path && ourChange.Kind == theirChange.Kind && equalValues(opts.at(ourChange.Path), ourChange.New, theirChange.New) {
+				changes = appendChange(changes, ourChange)
+				continue
+			}
+			path := ourChange.Path
+			if len(theirChange.Path) < len(path) {
+				path = theirChange.Path
+			}
+			conflict := Conflict{Path: path, Ours: ourChange, Theirs: theirChange}
+			conflicts = append(conflicts, conflict)
+			resolved, err := resolve(conflict)
+			if err != nil {
+				return nil, conflicts, err
+			}
+			changes = appendChange(changes, resolved)
+		}
+	}
+	for _, change := range oursChanges {
+		if !conflicted["o"+change.Path] {
+			changes = append(changes, change)
+		}
+	}
+	for _, change := range theirsChanges {
+		if !conflicted["t"+change.Path] {
+			changes = append(changes, change)
+		}
+	}
+	// parents first so changes of their children are applied on top
+	sort.SliceStable(changes, func(i, j int) bool {
+		return len(splitPath(changes[i].Path)) < len(splitPath(changes[j].Path))
+	})
+
+	mergedVal, err := applyChanges(baseVal, changes)
+	if err != nil {
+		return nil, conflicts, err
+	}
+	merged, err = json.Marshal(mergedVal)
+	if err != nil {
+		return nil, conflicts, fmt.Errorf("merged json encoding failed: %w", err)
+	}
+	return merged, conflicts, nil
+}
+
+// appendChange adds the change unless it was already added, which happens when a change conflicts with several others.
+func appendChange(changes []Change, change Change) []Change {
+	for _, c := range changes {
+		if reflect.DeepEqual(c, change) {
+			return changes
+		}
+	}
+	return append(changes, change)
+}
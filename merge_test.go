@@ -0,0 +1,79 @@
+package gobo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	base := `{"id":1014336373145370625, "name":"John", "age":32, "meta":{"country":"Argentina", "city":"Rosario"}}`
+	t.Run("non-overlapping changes", func(t *testing.T) {
+		ours := `{"id":1014336373145370625, "name":"Jane", "age":32, "meta":{"country":"Argentina", "city":"Rosario"}}`
+		theirs := `{"id":1014336373145370625, "name":"John", "age":33, "meta":{"country":"Argentina", "city":"Cordoba"}, "email":"john@mail.com"}`
+		merged, conflicts, err := Merge([]byte(base), []byte(ours), []byte(theirs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, conflicts)
+		assert.JSONEq(t, `{"id":1014336373145370625, "name":"Jane", "age":33, "meta":{"country":"Argentina", "city":"Cordoba"}, "email":"john@mail.com"}`, string(merged))
+	})
+	t.Run("same change on both sides", func(t *testing.T) {
+		ours := `{"id":1014336373145370625, "name":"Jane", "age":32, "meta":{"country":"Argentina", "city":"Rosario"}}`
+		merged, conflicts, err := Merge([]byte(base), []byte(ours), []byte(ours))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Empty(t, conflicts)
+		assert.JSONEq(t, ours, string(merged))
+	})
+	ours := `{"id":1014336373145370625, "name":"Jane", "age":32, "meta":{"country":"Brazil", "city":"Rosario"}}`
+	theirs := `{"id":1014336373145370625, "name":"Jim", "age":32}`
+	t.Run("fail by default", func(t *testing.T) {
+		merged, conflicts, err := Merge([]byte(base), []byte(ours), []byte(theirs))
+		assert.True(t, errors.Is(err, ErrMergeConflict))
+		assert.Nil(t, merged)
+		assert.NotEmpty(t, conflicts)
+	})
+	t.Run("resolve ours", func(t *testing.T) {
+		merged, conflicts, err := Merge([]byte(base), []byte(ours), []byte(theirs), UseConflictResolver(ResolveOurs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Conflict{
+			{
+				Path:   "/meta",
+				Ours:   Change{Path: "/meta/country", Kind: ChangeModified, Old: "Argentina", New: "Brazil"},
+				Theirs: Change{Path: "/meta", Kind: ChangeRemoved, Old: map[string]interface{}{"country": "Argentina", "city": "Rosario"}},
+			},
+			{
+				Path:   "/name",
+				Ours:   Change{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"},
+				Theirs: Change{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jim"},
+			},
+		}
+		assert.Equal(t, expected, conflicts)
+		assert.JSONEq(t, ours, string(merged))
+	})
+	t.Run("resolve theirs", func(t *testing.T) {
+		merged, _, err := Merge([]byte(base), []byte(ours), []byte(theirs), UseConflictResolver(ResolveTheirs))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, theirs, string(merged))
+	})
+	t.Run("custom resolver", func(t *testing.T) {
+		resolver := func(c Conflict) (Change, error) {
+			if c.Ours.Kind == ChangeModified && c.Theirs.Kind == ChangeModified {
+				return Change{Path: c.Path, Kind: ChangeModified, New: c.Ours.New.(string) + " & " + c.Theirs.New.(string)}, nil
+			}
+			return c.Ours, nil
+		}
+		merged, _, err := Merge([]byte(base), []byte(ours), []byte(theirs), UseConflictResolver(resolver))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, `{"id":1014336373145370625, "name":"Jane & Jim", "age":32, "meta":{"country":"Brazil", "city":"Rosario"}}`, string(merged))
+	})
+}
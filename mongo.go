@@ -0,0 +1,108 @@
+package gobo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchWithMongoUpdate returns the MongoDB filter and update documents that turn the original document into the new one.
+// The filter matches the idKey value of the original document, and the idKey field is never updated.
+//
+// Changed and added values are written with $set and removed ones with $unset, using dotted paths for nested fields.
+// Arrays follow the slice options: by default the new items are added with $addToSet, UseAddNewSlice adds all of them with $push
+// and UseReplaceSlice sets the new array, or removes the missing items with $pull when no item was added or moved.
+// Numbers are int64 or float64, so both maps can be given to any BSON encoder.
+func PatchWithMongoUpdate(original, new []byte, idKey string, optFuncs ...Option) (filter, update map[string]interface{}, err error) {
+	opts := newOptions(optFuncs)
+
+	var originalVal, newVal map[string]interface{}
+	if err = decodeDocument("original", original, &originalVal, true, opts); err != nil {
+		return nil, nil, err
+	}
+	if err = decodeDocument("new", new, &newVal, true, opts); err != nil {
+		return nil, nil, err
+	}
+	idVal, found := originalVal[idKey]
+	if idKey == "" || !found {
+		return nil, nil, ErrNoCondition
+	}
+	delete(originalVal, idKey)
+	delete(newVal, idKey)
+
+	u := mongoUpdate{opts: opts, ops: make(map[string]map[string]interface{})}
+	err = diffUpdate(originalVal, newVal, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, nil, err
+	}
+	if len(u.ops) == 0 {
+		return nil, nil, ErrNoDiff
+	}
+	update = make(map[string]interface{}, len(u.ops))
+	for op, fields := range u.ops {
+		update[op] = fields
+	}
+	return map[string]interface{}{idKey: bsonValue(idVal)}, update, nil
+}
+
+// mongoUpdate collects the fields of each update operator.
+type mongoUpdate struct {
+	opts Options
+	ops  map[string]map[string]interface{}
+}
+
+func (u *mongoUpdate) set(path string, segs []string, _, new interface{}) error {
+	return u.write("$set", path, segs, bsonValue(new))
+}
+
+func (u *mongoUpdate) remove(path string, segs []string) error {
+	return u.write("$unset", path, segs, "")
+}
+
+func (u *mongoUpdate) array(path string, segs []string, original, new []interface{}, opts Options) error {
+	switch {
+	case opts.AddNewSlice:
+		return u.write("$push", path, segs, map[string]interface{}{"$each": bsonValue(new)})
+	case opts.ReplaceSlice:
+		if removed, ok := removedItems(original, new, opts); ok {
+			return u.write("$pull", path, segs, map[string]interface{}{"$in": bsonValue(removed)})
+		}
+		return u.write("$set", path, segs, bsonValue(new))
+	}
+	if added := addedItems(original, new, opts); len(added) > 0 {
+		return u.write("$addToSet", path, segs, map[string]interface{}{"$each": bsonValue(added)})
+	}
+	return nil
+}
+
+// write adds the field to the operator, failing when a key can't be written in a dotted path.
+func (u *mongoUpdate) write(op, path string, segs []string, v interface{}) error {
+	for _, seg := range segs {
+		if seg == "" || strings.Contains(seg, ".") || strings.HasPrefix(seg, "$") {
+			return u.opts.fail(&PathError{Path: path, Reason: fmt.Errorf("%w: field name %q", ErrUnsupportedType, seg)})
+		}
+	}
+	if u.ops[op] == nil {
+		u.ops[op] = make(map[string]interface{})
+	}
+	u.ops[op][strings.Join(segs, ".")] = v
+	return nil
+}
+
+// bsonValue converts the json numbers of a value to int64 or float64.
+func bsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = bsonValue(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = bsonValue(item)
+		}
+		return s
+	}
+	return queryValue(v)
+}
@@ -0,0 +1,70 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMongoUpdate(t *testing.T) {
+	original := `{"_id":"u1", "name":"John", "age":32, "phone":"123", "address":{"city":"Rosario", "zip":"2000"}, "tags":["a", "b"]}`
+	new := `{"_id":"u1", "name":"Jane", "age":30.5, "address":{"city":"Córdoba", "zip":"2000", "street":"Colón"}, "tags":["a", "b", "c"]}`
+	t.Run("set, unset and add to set", func(t *testing.T) {
+		filter, update, err := PatchWithMongoUpdate([]byte(original), []byte(new), "_id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"_id": "u1"}, filter)
+		expected := map[string]interface{}{
+			"$set": map[string]interface{}{
+				"name":           "Jane",
+				"age":            30.5,
+				"address.city":   "Córdoba",
+				"address.street": "Colón",
+			},
+			"$unset":    map[string]interface{}{"phone": ""},
+			"$addToSet": map[string]interface{}{"tags": map[string]interface{}{"$each": []interface{}{"c"}}},
+		}
+		assert.Equal(t, expected, update)
+	})
+	t.Run("slice options", func(t *testing.T) {
+		original := `{"id":1, "tags":["a", "b", "c"], "history":[1, 2], "coords":[1, 2]}`
+		new := `{"id":1, "tags":["a", "c"], "history":[1, 2, 3], "coords":[2, 1]}`
+		filter, update, err := PatchWithMongoUpdate([]byte(original), []byte(new), "id",
+			UsePathOptions("/tags", UseReplaceSlice()), UsePathOptions("/coords", UseReplaceSlice()),
+			UsePathOptions("/history", UseAddNewSlice()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"id": int64(1)}, filter)
+		expected := map[string]interface{}{
+			"$pull": map[string]interface{}{"tags": map[string]interface{}{"$in": []interface{}{"b"}}},
+			"$set":  map[string]interface{}{"coords": []interface{}{int64(2), int64(1)}},
+			"$push": map[string]interface{}{"history": map[string]interface{}{"$each": []interface{}{int64(1), int64(2), int64(3)}}},
+		}
+		assert.Equal(t, expected, update)
+	})
+	t.Run("filters and type changes", func(t *testing.T) {
+		_, update, err := PatchWithMongoUpdate([]byte(original), []byte(new), "_id", UseIncludePaths("/address/city"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"$set": map[string]interface{}{"address.city": "Córdoba"}}, update)
+
+		_, _, err = PatchWithMongoUpdate([]byte(`{"_id":1, "age":1}`), []byte(`{"_id":1, "age":"1"}`), "_id", UseRejectTypeChange())
+		assert.ErrorIs(t, err, ErrTypeChanged)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := PatchWithMongoUpdate([]byte(original), []byte(new), "id")
+		assert.Equal(t, ErrNoCondition, err)
+		_, _, err = PatchWithMongoUpdate([]byte(original), []byte(original), "_id")
+		assert.Equal(t, ErrNoDiff, err)
+		_, _, err = PatchWithMongoUpdate([]byte(`{"_id":1, "a.b":1}`), []byte(`{"_id":1, "a.b":2}`), "_id")
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		_, update, err := PatchWithMongoUpdate([]byte(`{"_id":1, "a.b":1, "c":1}`), []byte(`{"_id":1, "a.b":1, "c":2}`), "_id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"$set": map[string]interface{}{"c": int64(2)}}, update)
+	})
+}
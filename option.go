@@ -1,12 +1,66 @@
 package gobo
 
+import (
+	"context"
+	"log/slog"
+)
+
 type Options struct {
 	ReplaceSlice bool
 	AddNewSlice  bool
+	MergeSlice   bool
+	Ignore       bool
+	// RejectTypeChange makes the diff fail with ErrTypeChanged when a value changes its json kind.
+	RejectTypeChange bool
+	// CollectErrors makes the diff report every problem found joined with errors.Join instead of stopping at the first one.
+	CollectErrors bool
+	// Parallelism is the number of goroutines comparing sibling values of large objects and arrays, zero or one compares them sequentially.
+	Parallelism int
+	// StreamBuffer limits the keys StreamChanges keeps in memory while objects are out of order, zero means no limit.
+	StreamBuffer int
+	// MaxBytes, MaxDepth, MaxKeys and MaxArrayLength limit the documents accepted, zero means no limit.
+	MaxBytes       int
+	MaxDepth       int
+	MaxKeys        int
+	MaxArrayLength int
+	paths          []pathRule
+	include        [][]string
+	comparators    []Comparator
+	resolver       ConflictResolver
+	logger         *slog.Logger
+	decoder        Decoder
+	ctx            context.Context
+	// format is the name given to UseFormat, written in the parse errors of the decoder.
+	format string
+	// counters and lockField configure PatchWithDynamoUpdate.
+	counters  [][]string
+	lockField string
+	// hashes are the subtree hashes of both documents when the original one is a Document.
+	hashes *hashPair
+	// errs collects the errors when CollectErrors is true.
+	errs *collectedErrors
+	// workers holds a token for each goroutine comparing values besides the caller when Parallelism is greater than one.
+	workers chan struct{}
+	// partial is set for the ancestors of included values, they are traversed but not reported.
+	partial bool
 }
 
 type Option func(*Options)
 
+func newOptions(optFuncs []Option) Options {
+	opts := Options{}
+	for _, optFunc := range optFuncs {
+		optFunc(&opts)
+	}
+	if opts.CollectErrors {
+		opts.errs = new(collectedErrors)
+	}
+	if opts.Parallelism > 1 {
+		opts.workers = make(chan struct{}, opts.Parallelism-1)
+	}
+	return opts
+}
+
 // If ReplaceSlice is true, it will replace the original slice with the new one.
 // If it's false (default), it will conserve the differences of the new slice and the original data.
 func UseReplaceSlice() Option {
@@ -21,3 +75,163 @@ func UseAddNewSlice() Option {
 		opts.AddNewSlice = true
 	}
 }
+
+// If MergeSlice is true, it will conserve the original slice and add the differences of the new one (default behavior).
+// It's useful inside UsePathOptions to go back to the default when a global slice option was given.
+func UseMergeSlice() Option {
+	return func(opts *Options) {
+		opts.MergeSlice = true
+	}
+}
+
+// If Ignore is true, differences of the value and its children won't be reported.
+// It's meant to be used inside UsePathOptions.
+func UseIgnore() Option {
+	return func(opts *Options) {
+		opts.Ignore = true
+	}
+}
+
+// If RejectTypeChange is true, a value that changes its json kind (object, array, string, number, boolean)
+// is considered a schema violation and the diff fails with ErrTypeChanged. Changes from or to null are allowed.
+func UseRejectTypeChange() Option {
+	return func(opts *Options) {
+		opts.RejectTypeChange = true
+	}
+}
+
+// If CollectErrors is true, every key conflict, rejected type change or invalid value is reported in one pass.
+// The returned error joins a *PathError for each of them, so API consumers get field-level messages.
+func UseCollectErrors() Option {
+	return func(opts *Options) {
+		opts.CollectErrors = true
+	}
+}
+
+// UseMaxBytes rejects documents longer than n bytes with ErrMaxBytes before decoding them.
+func UseMaxBytes(n int) Option {
+	return func(opts *Options) {
+		opts.MaxBytes = n
+	}
+}
+
+// UseMaxDepth rejects documents with objects or arrays nested more than n levels with ErrMaxDepth.
+func UseMaxDepth(n int) Option {
+	return func(opts *Options) {
+		opts.MaxDepth = n
+	}
+}
+
+// UseMaxKeys rejects documents with an object of more than n keys with ErrMaxKeys.
+func UseMaxKeys(n int) Option {
+	return func(opts *Options) {
+		opts.MaxKeys = n
+	}
+}
+
+// UseMaxArrayLength rejects documents with an array of more than n items with ErrMaxArrayLength.
+func UseMaxArrayLength(n int) Option {
+	return func(opts *Options) {
+		opts.MaxArrayLength = n
+	}
+}
+
+// UseParallelism compares the keys of large objects and the items of large arrays using up to n goroutines,
+// shared by the whole diff. Results don't depend on the parallelism: keys are always merged in key order, so nested keys
+// flattened with the same name resolve the same way. Comparators and loggers given with it must be safe for concurrent use.
+func UseParallelism(n int) Option {
+	return func(opts *Options) {
+		opts.Parallelism = n
+	}
+}
+
+// UseStreamBuffer makes StreamChanges fail with ErrStreamBuffer when more than n keys of an object
+// are waiting for the other document to reach them. Documents with keys in the same order need no buffer.
+func UseStreamBuffer(n int) Option {
+	return func(opts *Options) {
+		opts.StreamBuffer = n
+	}
+}
+
+// UseComparator adds a comparator to decide when values are equal, for example to ignore the case of emails.
+// Comparators are consulted in the order they were added, and the ones given inside UsePathOptions before the global ones.
+// Values not handled by any comparator are compared deeply.
+func UseComparator(c Comparator) Option {
+	return func(opts *Options) {
+		opts.comparators = append(opts.comparators, c)
+	}
+}
+
+// UseConflictResolver sets how Merge resolves the conflicts: ResolveOurs, ResolveTheirs, ResolveFail (default) or a custom function.
+func UseConflictResolver(r ConflictResolver) Option {
+	return func(opts *Options) {
+		opts.resolver = r
+	}
+}
+
+// UseLogger sets the logger used to report diagnostics such as key conflicts, type changes and unsupported values.
+// Nothing is logged by default.
+func UseLogger(logger *slog.Logger) Option {
+	return func(opts *Options) {
+		opts.logger = logger
+	}
+}
+
+// UsePathOptions applies the given options only to the values matched by the pattern and their children.
+// It accepts slice strategies, UseIgnore, UseRejectTypeChange and UseComparator.
+//
+// The pattern is a JSON Pointer like "/meta/tags" where each segment can be a glob ("*", "user?", "[ab]*")
+// and "**" matches any number of segments. Array items are addressed by their index ("/history/0").
+// Path options override the global ones. When several patterns match, the most specific wins:
+// the one with more literal segments, then the one with more segments, then the last one added.
+func UsePathOptions(pattern string, optFuncs ...Option) Option {
+	return func(opts *Options) {
+		local := Options{}
+		for _, optFunc := range optFuncs {
+			optFunc(&local)
+		}
+		opts.paths = append(opts.paths, pathRule{
+			pattern: pattern,
+			segs:    splitPath(pattern),
+			opts:    local,
+			order:   len(opts.paths),
+		})
+		sortRules(opts.paths)
+	}
+}
+
+// UseExcludePaths ignores the values matched by the patterns and their children, for example volatile fields
+// such as "/updated_at" or "/**/etag". Patterns follow the same rules as UsePathOptions.
+//
+// With PatchWithQuery the patterns are matched against the json keys, not the database attributes.
+func UseExcludePaths(patterns ...string) Option {
+	return func(opts *Options) {
+		for _, pattern := range patterns {
+			UsePathOptions(pattern, UseIgnore())(opts)
+		}
+	}
+}
+
+// UseIncludePaths restricts the differences to the values matched by the patterns and their children.
+// Everything else is ignored, as if it was excluded. Exclusions win over inclusions.
+//
+// With PatchWithQuery the patterns are matched against the json keys, not the database attributes.
+func UseIncludePaths(patterns ...string) Option {
+	return func(opts *Options) {
+		for _, pattern := range patterns {
+			opts.include = append(opts.include, splitPath(pattern))
+		}
+	}
+}
+
+func (o Options) logDebug(msg string, args ...any) {
+	if o.logger != nil {
+		o.logger.Log(context.Background(), slog.LevelDebug, msg, args...)
+	}
+}
+
+func (o Options) logWarn(msg string, args ...any) {
+	if o.logger != nil {
+		o.logger.Log(context.Background(), slog.LevelWarn, msg, args...)
+	}
+}
@@ -0,0 +1,50 @@
+package gobo
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// parallelThreshold is the number of siblings from which comparing them in parallel pays off.
+const parallelThreshold = 64
+
+// forEach calls fn for every index below n. With UseParallelism, large sets of siblings are spread over the workers
+// of the diff, and the calling goroutine works too, so nested calls never wait for a worker.
+// fn must write its result to its own index to keep the results in order.
+// Once the context of the diff is canceled the remaining indexes are skipped, callers return opts.canceled() then.
+func (o Options) forEach(n int, fn func(i int)) {
+	if o.workers == nil || n < parallelThreshold {
+		for i := range n {
+			if o.canceled() != nil {
+				return
+			}
+			fn(i)
+		}
+		return
+	}
+	var next atomic.Int64
+	work := func() {
+		for i := int(next.Add(1)) - 1; i < n && o.canceled() == nil; i = int(next.Add(1)) - 1 {
+			fn(i)
+		}
+	}
+	var wg sync.WaitGroup
+spawn:
+	for range cap(o.workers) {
+		select {
+		case o.workers <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-o.workers
+					wg.Done()
+				}()
+				work()
+			}()
+		default:
+			break spawn
+		}
+	}
+	work()
+	wg.Wait()
+}
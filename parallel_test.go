@@ -0,0 +1,97 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallelism(t *testing.T) {
+	t.Run("wide objects", func(t *testing.T) {
+		original, new := wideDocuments(500)
+		expected, err := JSONDiff(original, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := JSONDiff(original, new, UseParallelism(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("large arrays", func(t *testing.T) {
+		original, new := deepDocuments(200, 5)
+		for _, optFunc := range []Option{UseMergeSlice(), UseAddNewSlice(), UseReplaceSlice()} {
+			expected, err := JSONDiff(original, new, optFunc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff, err := JSONDiff(original, new, optFunc, UseParallelism(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, expected, diff)
+		}
+		expectedChanges, err := Changes(original, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := Changes(original, new, UseParallelism(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, expectedChanges, changes)
+	})
+	t.Run("flattened keys with the same name", func(t *testing.T) {
+		orig := map[string]interface{}{"name": "a", "meta": map[string]interface{}{"name": "b"}}
+		upd := map[string]interface{}{"name": "c", "meta": map[string]interface{}{"name": "d"}}
+		for i := range 100 {
+			orig[fmt.Sprintf("key_%03d", i)] = i
+			upd[fmt.Sprintf("key_%03d", i)] = i
+		}
+		original, _ := json.Marshal(orig)
+		new, _ := json.Marshal(upd)
+		for range 10 {
+			diff, err := JSONDiff(original, new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, map[string]interface{}{"name": "c"}, diff)
+			diff, err = JSONDiff(original, new, UseParallelism(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, map[string]interface{}{"name": "c"}, diff)
+		}
+	})
+	t.Run("errors in order", func(t *testing.T) {
+		orig := make(map[string]interface{})
+		upd := make(map[string]interface{})
+		for i := range 100 {
+			orig[fmt.Sprintf("key_%03d", i)] = []interface{}{i}
+			upd[fmt.Sprintf("key_%03d", i)] = fmt.Sprint(i)
+		}
+		original, _ := json.Marshal(orig)
+		new, _ := json.Marshal(upd)
+		_, err := Changes(original, new, UseRejectTypeChange(), UseParallelism(8))
+		var pathErr *PathError
+		if assert.ErrorAs(t, err, &pathErr) {
+			assert.Equal(t, "/key_000", pathErr.Path)
+		}
+		_, expectedErr := Changes(original, new, UseRejectTypeChange(), UseCollectErrors())
+		_, err = Changes(original, new, UseRejectTypeChange(), UseCollectErrors(), UseParallelism(8))
+		assert.Equal(t, expectedErr.Error(), err.Error())
+	})
+}
+
+func BenchmarkJSONDiffWideParallel(b *testing.B) {
+	original, new := wideDocuments(500)
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := JSONDiff(original, new, UseParallelism(4)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package gobo
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// pathRule holds the options that apply to every value whose JSON Pointer is matched by pattern.
+type pathRule struct {
+	pattern string
+	segs    []string
+	opts    Options
+	order   int
+}
+
+// joinPath appends the key to the JSON Pointer escaping it as RFC 6901 describes.
+func joinPath(parent, key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	return parent + "/" + key
+}
+
+// splitPath returns the unescaped segments of a JSON Pointer. The root pointer ("" or "/") has no segments.
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return nil
+	}
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		s = strings.ReplaceAll(s, "~1", "/")
+		segs[i] = strings.ReplaceAll(s, "~0", "~")
+	}
+	return segs
+}
+
+// matchPattern reports whether the pattern matches the path or one of its ancestors, so a rule applies to the whole subtree.
+// Each segment is matched with path.Match, and a "**" segment matches any number of segments.
+func matchPattern(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return true
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchPattern(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 || !matchSegment(pat[0], segs[0]) {
+		return false
+	}
+	return matchPattern(pat[1:], segs[1:])
+}
+
+// matchAncestor reports whether the path is an ancestor of some value the pattern can match,
+// meaning that the traversal has to go down the path to find it.
+func matchAncestor(pat, segs []string) bool {
+	if len(segs) == 0 {
+		return len(pat) > 0
+	}
+	if len(pat) == 0 {
+		return false
+	}
+	if pat[0] == "**" {
+		return true
+	}
+	if !matchSegment(pat[0], segs[0]) {
+		return false
+	}
+	return matchAncestor(pat[1:], segs[1:])
+}
+
+// matchSegment matches a single segment. Malformed globs are compared literally.
+func matchSegment(pat, seg string) bool {
+	ok, err := path.Match(pat, seg)
+	if err != nil {
+		return pat == seg
+	}
+	return ok
+}
+
+// specificity ranks patterns: more literal segments first, then more segments. "**" does not count.
+func specificity(segs []string) (literals, total int) {
+	for _, s := range segs {
+		if s == "**" {
+			continue
+		}
+		total++
+		if !strings.ContainsAny(s, `*?[\`) {
+			literals++
+		}
+	}
+	return literals, total
+}
+
+// sortRules orders the rules from the least to the most specific so the last applied wins.
+// Rules with the same specificity keep the order in which they were registered.
+func sortRules(rules []pathRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		li, ti := specificity(rules[i].segs)
+		lj, tj := specificity(rules[j].segs)
+		if li != lj {
+			return li < lj
+		}
+		if ti != tj {
+			return ti < tj
+		}
+		return rules[i].order < rules[j].order
+	})
+}
+
+// at returns the effective options for the value placed at the given JSON Pointer.
+// Global options are overridden by every matching path rule, from the least to the most specific one.
+// When include patterns were given, values outside them are ignored and their ancestors are only traversed.
+func (o Options) at(p string) Options {
+	if len(o.paths) == 0 && len(o.include) == 0 {
+		return o
+	}
+	segs := splitPath(p)
+	eff := o
+	for _, rule := range o.paths {
+		if matchPattern(rule.segs, segs) {
+			eff = eff.overlay(rule.opts)
+		}
+	}
+	if len(o.include) > 0 && !eff.Ignore {
+		var included, ancestor bool
+		for _, pat := range o.include {
+			if matchPattern(pat, segs) {
+				included = true
+				break
+			}
+			ancestor = ancestor || matchAncestor(pat, segs)
+		}
+		if !included {
+			eff.Ignore = !ancestor
+			eff.partial = ancestor
+		}
+	}
+	return eff
+}
+
+// skipped reports whether a leaf value placed at these options must be left out of the differences.
+func (o Options) skipped() bool {
+	return o.Ignore || o.partial
+}
+
+// overlay replaces the settings of o with the ones explicitly set in local.
+func (o Options) overlay(local Options) Options {
+	if local.ReplaceSlice || local.AddNewSlice || local.MergeSlice {
+		o.ReplaceSlice, o.AddNewSlice, o.MergeSlice = local.ReplaceSlice, local.AddNewSlice, local.MergeSlice
+	}
+	if local.Ignore {
+		o.Ignore = true
+	}
+	if local.RejectTypeChange {
+		o.RejectTypeChange = true
+	}
+	if len(local.comparators) > 0 {
+		o.comparators = append(append([]Comparator{}, local.comparators...), o.comparators...)
+	}
+	return o
+}
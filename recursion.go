@@ -1,86 +1,51 @@
 package gobo
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 // Detect all kind of changes such as slices and nested json.
 // The goal is for it to be general purpose differences detector while simpleMapIterator is used to build sql queries from a flat structure.
-func iterateMaps(original, new map[string]interface{}, opts Options) (map[string]interface{}, error) {
+func iterateMaps(original, new map[string]interface{}, opts Options, path string) (map[string]interface{}, error) {
+	if err := opts.canceled(); err != nil {
+		return nil, err
+	}
 	diff := make(map[string]interface{})
-	for k, v := range new {
-		for k2, v2 := range original {
-			if k != k2 && v == v2 {
-				return nil, ErrKeyConflict
-			} else if k == k2 {
-				switch reflect.TypeOf(v).Kind() {
-				case reflect.Float64:
-					diff[k] = v
-				case reflect.Slice:
-					{
-						new := reflect.ValueOf(v)
-						orig := reflect.ValueOf(v2)
-						var newSli []interface{}
-						var origSli []interface{}
-						for i := range new.Len() {
-							if new.Kind() == reflect.ValueOf(v).Kind() {
-								newSli = append(newSli, new.Index(i).Interface())
-							} else {
-								newSli = append(newSli, new.Index(i))
-							}
-						}
-						for i := range orig.Len() {
-							if orig.Kind() == reflect.ValueOf(v2).Kind() {
-								origSli = append(origSli, orig.Index(i).Interface())
-							} else {
-								origSli = append(origSli, orig.Index(i))
-							}
-						}
-						if orig, new, areEqual := equalSlices(origSli, newSli); !areEqual {
-							if orig != nil {
-								diffOfMap, err := iterateMaps(orig, new, opts)
-								if err != nil {
-									return nil, err
-								}
-								diff[k] = diffOfMap
-							} else if opts.AddNewSlice {
-								diff[k] = appendNewSlice(origSli, newSli)
-							} else if opts.ReplaceSlice {
-								diff[k] = newSli
-							} else {
-								diff[k] = appendNewSliceDiffs(origSli, newSli)
-							}
-							break
-						}
-					}
-				default:
-					if _, ok := v2.(map[string]interface{}); ok {
-						// nested json
-						originalMap, newMap := convertToMap(v2, v)
-						for k, v := range newMap {
-							for k2, v2 := range originalMap {
-								if k == k2 {
-									if _, ok := v.([]interface{}); ok {
-										diff = handleSlice(v, v2, diff, k, opts)
-										break
-									} else if v != v2 {
-										diff[k] = v
-									}
-								}
-							}
-						}
-						break
-					} else if v != v2 {
-						diff[k] = v
-						break
-					}
-				}
+	index := newValueIndex(original)
+	// keys are merged in order, so nested keys flattened with the same name always resolve the same way
+	keys := make([]string, 0, len(new))
+	for k := range new {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if opts.workers != nil && len(keys) >= parallelThreshold {
+		diffs := make([]map[string]interface{}, len(keys))
+		errs := make([]error, len(keys))
+		opts.forEach(len(keys), func(i int) {
+			diffs[i] = make(map[string]interface{})
+			errs[i] = diffKey(diffs[i], original, new[keys[i]], index, keys[i], opts, path)
+		})
+		if err := opts.canceled(); err != nil {
+			return nil, err
+		}
+		for i := range keys {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			for k, v := range diffs[i] {
+				diff[k] = v
+			}
+		}
+	} else {
+		for _, k := range keys {
+			if err := diffKey(diff, original, new[k], index, k, opts, path); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -90,65 +55,212 @@ func iterateMaps(original, new map[string]interface{}, opts Options) (map[string
 	return diff, nil
 }
 
-// Detect changes in flat json structures such as strings and numbers. Used in DoPatchWithQuery method to create the queries.
-func simpleMapIterator(original, new map[string]interface{}, ignoreEmpty bool) (map[string]interface{}, error) {
-	diff := make(map[string]interface{})
-	if ignoreEmpty {
-		for k, v := range new {
-			if foundID(k) {
+// diffKey writes to diff the differences of the key of the object at path, whose new value is v.
+func diffKey(diff, original map[string]interface{}, v interface{}, index valueIndex, k string, opts Options, path string) error {
+	keyPath := joinPath(path, k)
+	keyOpts := opts.at(keyPath)
+	if keyOpts.Ignore {
+		return nil
+	}
+	if err := keyConflicts(index, original, path, k, v, opts); err != nil {
+		return err
+	}
+	v2, found := original[k]
+	if !found {
+		return nil
+	}
+	if jsonKind(v) != jsonKind(v2) {
+		if err := checkTypeChange(keyOpts, keyPath, v2, v); err != nil {
+			return err
+		}
+		opts.logDebug("type changed", "path", keyPath, "from", jsonKind(v2), "to", jsonKind(v))
+		if !keyOpts.partial {
+			diff[k] = v
+		}
+		return nil
+	}
+	if v == nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case float64:
+		if !keyOpts.partial && !equalValues(keyOpts, v2, v) {
+			diff[k] = v
+		}
+	case []interface{}:
+		if opts.unchanged(keyPath) {
+			return nil
+		}
+		sliceDiff, changed, err := diffSlices(v2.([]interface{}), v, opts, keyPath)
+		if err != nil {
+			return err
+		}
+		if changed {
+			diff[k] = sliceDiff
+		}
+	case map[string]interface{}:
+		// nested json
+		originalMap := v2.(map[string]interface{})
+		// nested slices are reported even when they're equal, so only the other values can skip an unchanged object
+		unchanged := opts.unchanged(keyPath)
+		for k, v := range v {
+			nestedPath := joinPath(keyPath, k)
+			nestedOpts := opts.at(nestedPath)
+			if nestedOpts.skipped() {
+				continue
+			}
+			v2, found := originalMap[k]
+			if !found {
 				continue
 			}
-			for k2, v2 := range original {
-				if k != k2 && v == v2 {
-					return nil, ErrKeyConflict
-				} else if k == k2 {
-					switch v := v.(type) {
-					case json.Number:
-						if intVal, err := v.Int64(); err == nil {
-							diff[k] = intVal
-						} else if floatVal, err := v.Float64(); err == nil {
-							diff[k] = floatVal
-						} else {
-							log.Println("Error parsing number:", err)
-						}
-					case string:
-						if v != "" && v != v2 {
-							diff[k] = v
-							break
-						}
-					default:
-						log.Println("Unhandled type for key:", k, "value:", v)
-					}
+			if jsonKind(v) != jsonKind(v2) {
+				if err := checkTypeChange(nestedOpts, nestedPath, v2, v); err != nil {
+					return err
+				}
+				diff[k] = v
+			} else if newSli, ok := v.([]interface{}); ok {
+				diff = handleSlice(v2.([]interface{}), newSli, diff, k, nestedOpts)
+				if err := opts.canceled(); err != nil {
+					return err
 				}
+			} else if !unchanged && !equalValues(nestedOpts, v2, v) {
+				diff[k] = v
 			}
 		}
-	} else {
-		for k, v := range new {
-			if foundID(k) {
-				continue
+	default:
+		if !keyOpts.partial && !equalValues(keyOpts, v2, v) {
+			diff[k] = v
+		}
+	}
+	return nil
+}
+
+// diffSlices applies the slice strategy configured for the path and reports whether the slices are different.
+// When the first different items are both objects, only the differences of the first object with differences
+// that aren't ignored are returned.
+func diffSlices(origSli, newSli []interface{}, opts Options, path string) (interface{}, bool, error) {
+	sliceOpts := opts.at(path)
+	orig, _, idx, areEqual := equalSlices(origSli, newSli, sliceOpts)
+	if err := opts.canceled(); err != nil {
+		return nil, false, err
+	}
+	if areEqual {
+		return nil, false, nil
+	}
+	if orig != nil {
+		diffOfMap, changed, err := diffSliceItems(origSli, newSli, opts, path, idx)
+		if err != nil || diffOfMap != nil || !changed {
+			return diffOfMap, changed, err
+		}
+	}
+	switch {
+	case sliceOpts.partial:
+		return nil, false, nil
+	case sliceOpts.AddNewSlice:
+		return appendNewSlice(origSli, newSli), true, nil
+	case sliceOpts.ReplaceSlice:
+		return newSli, true, nil
+	default:
+		sliceDiff := appendNewSliceDiffs(origSli, newSli, sliceOpts)
+		if err := opts.canceled(); err != nil {
+			return nil, false, err
+		}
+		return sliceDiff, true, nil
+	}
+}
+
+// diffSliceItems compares the objects of slices with the same length from the index of the first different item,
+// skipping the ones whose differences are all ignored. It returns the differences of the first object changed,
+// or changed without differences when an item that isn't an object changed.
+func diffSliceItems(origSli, newSli []interface{}, opts Options, path string, from int) (map[string]interface{}, bool, error) {
+	sliceOpts := opts.at(path)
+	for i := from; i < len(newSli); i++ {
+		if equalValues(sliceOpts, origSli[i], newSli[i]) {
+			continue
+		}
+		origMap, origOk := origSli[i].(map[string]interface{})
+		newMap, newOk := newSli[i].(map[string]interface{})
+		if !origOk || !newOk {
+			return nil, true, nil
+		}
+		diffOfMap, err := iterateMaps(origMap, newMap, opts, joinPath(path, strconv.Itoa(i)))
+		if errors.Is(err, ErrNoDiff) {
+			// every difference of the item was ignored
+			continue
+		} else if err != nil {
+			return nil, false, err
+		}
+		return diffOfMap, true, nil
+	}
+	return nil, false, nil
+}
+
+// Detect changes in flat json structures such as strings and numbers. Used in DoPatchWithQuery method to create the queries.
+// With renames, a new value found in the original object under another key fails with ErrKeyConflict.
+// Structs don't check it, since their field names are fixed by the type.
+func simpleMapIterator(original, new map[string]interface{}, ignoreEmpty, renames bool, opts Options) (map[string]interface{}, error) {
+	diff := make(map[string]interface{})
+	var index valueIndex
+	if renames {
+		index = newValueIndex(original)
+	}
+	// sorted so the first key conflict found is always the same one
+	keys := make([]string, 0, len(new))
+	for k := range new {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := new[k]
+		keyPath := joinPath("", k)
+		keyOpts := opts.at(keyPath)
+		if foundID(k) || keyOpts.skipped() {
+			continue
+		}
+		if renames {
+			if err := keyConflicts(index, original, "", k, v, opts); err != nil {
+				return nil, err
+			}
+		}
+		v2, found := original[k]
+		if !found || equalValues(keyOpts, v2, v) {
+			continue
+		}
+		if jsonKind(v) != jsonKind(v2) {
+			if err := checkTypeChange(keyOpts, keyPath, v2, v); err != nil {
+				return nil, err
 			}
-			for k2, v2 := range original {
-				if k != k2 && v == v2 {
-					return nil, ErrKeyConflict
-				} else if k == k2 {
-					switch v := v.(type) {
-					case json.Number:
-						if intVal, err := v.Int64(); err == nil {
-							diff[k] = intVal
-						} else if floatVal, err := v.Float64(); err == nil {
-							diff[k] = floatVal
-						} else {
-						}
-					case string:
-						if v != v2 {
-							diff[k] = v
-							break
-						}
-					default:
-						log.Println("Unhandled type for key:", k, "value:", v)
-					}
+			opts.logDebug("type changed", "path", keyPath, "from", jsonKind(v2), "to", jsonKind(v))
+		}
+		switch v := v.(type) {
+		case json.Number:
+			if intVal, err := v.Int64(); err == nil {
+				diff[k] = intVal
+			} else if uintVal, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+				diff[k] = uintVal
+			} else if floatVal, err := v.Float64(); err == nil {
+				diff[k] = floatVal
+			} else {
+				opts.logWarn("invalid number", "path", keyPath, "value", v.String())
+				if err := opts.fail(&PathError{Path: keyPath, Old: v2, New: v, Reason: fmt.Errorf("%w: %v", ErrInvalidNumber, err)}); err != nil {
+					return nil, err
 				}
 			}
+		case string:
+			if !ignoreEmpty || v != "" {
+				diff[k] = v
+			}
+		case bool:
+			diff[k] = v
+		case nil:
+			if !ignoreEmpty {
+				diff[k] = nil
+			}
+		default:
+			opts.logWarn("unsupported type", "path", keyPath, "type", jsonKind(v))
+			if err := opts.fail(&PathError{Path: keyPath, Old: v2, New: v, Reason: fmt.Errorf("%w: %s", ErrUnsupportedType, jsonKind(v))}); err != nil {
+				return nil, err
+			}
 		}
 	}
 	if len(diff) == 0 {
@@ -157,121 +269,181 @@ func simpleMapIterator(original, new map[string]interface{}, ignoreEmpty bool) (
 	return diff, nil
 }
 
+// valueIndex maps the comparable values of an object to their keys, so renamed keys are found without comparing every pair.
+// Most values appear once, so only repeated values get a slice of keys.
+type valueIndex struct {
+	first    map[interface{}]string
+	repeated map[interface{}][]string
+}
+
+// newValueIndex indexes the object. Null values are left out, a field set to null on both sides doesn't mean that keys were renamed.
+func newValueIndex(m map[string]interface{}) valueIndex {
+	index := valueIndex{first: make(map[interface{}]string, len(m))}
+	for k, v := range m {
+		if v == nil || !reflect.TypeOf(v).Comparable() {
+			continue
+		}
+		if _, found := index.first[v]; !found {
+			index.first[v] = k
+			continue
+		}
+		if index.repeated == nil {
+			index.repeated = make(map[interface{}][]string)
+		}
+		index.repeated[v] = append(index.repeated[v], k)
+	}
+	return index
+}
+
+// keyConflicts fails with ErrKeyConflict when the new value is found in the original object under another key.
+func keyConflicts(index valueIndex, original map[string]interface{}, path, k string, v interface{}, opts Options) error {
+	if v == nil || !reflect.TypeOf(v).Comparable() {
+		return nil
+	}
+	first, found := index.first[v]
+	if !found {
+		return nil
+	}
+	candidates := append([]string{first}, index.repeated[v]...)
+	sort.Strings(candidates)
+	for _, k2 := range candidates {
+		if k2 == k || opts.at(joinPath(path, k2)).skipped() {
+			continue
+		}
+		opts.logWarn("key conflict", "path", joinPath(path, k), "original_path", joinPath(path, k2))
+		if err := opts.fail(&PathError{Path: joinPath(path, k), Old: original[k2], New: v, Reason: fmt.Errorf("%w: %s", ErrKeyConflict, joinPath(path, k2))}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func foundID(id string) bool {
 	return strings.Contains(strings.ToLower(id), "id")
 }
 
+// appendNewSlice returns a new slice with both items, the decoded original slice is never written.
 func appendNewSlice(original, new []interface{}) []interface{} {
-	original = append(original, new...)
-	return original
+	return append(original[:len(original):len(original)], new...)
 }
 
-func appendNewSliceDiffs(original, new []interface{}) []interface{} {
-	var diff []interface{}
-	var found bool
-	for i := range new {
-		found = true
+// appendNewSliceDiffs returns the original items followed by the new ones missing in the original slice.
+// Items are skipped once the context of the diff is canceled, see forEach.
+func appendNewSliceDiffs(original, new []interface{}, opts Options) []interface{} {
+	found := make([]bool, len(new))
+	opts.forEach(len(new), func(i int) {
+		found[i] = true
 		for j := range original {
-			if new[i] == original[j] {
-				found = false
+			if equalValues(opts, original[j], new[i]) {
+				found[i] = false
 				break
 			}
 		}
-		if found {
+	})
+	var diff []interface{}
+	for i := range new {
+		if found[i] {
 			diff = append(diff, new[i])
 		}
 	}
-	original = append(original, diff...)
-	return original
+	return append(original[:len(original):len(original)], diff...)
 }
 
-func equalSlices(originalSlice, newSlice []interface{}) (map[string]interface{}, map[string]interface{}, bool) {
+func equalSlices(originalSlice, newSlice []interface{}, opts Options) (map[string]interface{}, map[string]interface{}, int, bool) {
 	if len(originalSlice) != len(newSlice) {
-		return nil, nil, false
+		return nil, nil, -1, false
+	}
+	var equal []bool
+	if opts.workers != nil && len(originalSlice) >= parallelThreshold {
+		equal = make([]bool, len(originalSlice))
+		opts.forEach(len(originalSlice), func(i int) {
+			equal[i] = equalValues(opts, originalSlice[i], newSlice[i])
+		})
 	}
 	for i := range originalSlice {
-		if !reflect.DeepEqual(originalSlice[i], newSlice[i]) {
-			if reflect.TypeOf(originalSlice[i]).Kind() == reflect.Map && reflect.TypeOf(newSlice[i]).Kind() == reflect.Map {
-				return originalSlice[i].(map[string]interface{}), newSlice[i].(map[string]interface{}), false
-			} else {
-				return nil, nil, false
+		if opts.canceled() != nil {
+			// the caller returns the error of the context
+			return nil, nil, -1, false
+		}
+		if equal != nil && !equal[i] || equal == nil && !equalValues(opts, originalSlice[i], newSlice[i]) {
+			origMap, origOk := originalSlice[i].(map[string]interface{})
+			newMap, newOk := newSlice[i].(map[string]interface{})
+			if origOk && newOk {
+				return origMap, newMap, i, false
 			}
+			return nil, nil, i, false
 		}
 	}
-	return nil, nil, true
-}
-
-func convertToMap[T reflect.Value | interface{}](original, new T) (originalMap, newMap map[string]interface{}) {
-	originalMap = make(map[string]interface{})
-	newMap = make(map[string]interface{})
-	newBytes, err := json.Marshal(new)
-	if err != nil {
-		log.Fatalf("Failed new map marshal: %v", err)
-	}
-	originalBytes, err := json.Marshal(original)
-	if err != nil {
-		log.Fatalf("Failed original map marshal: %v", err)
-	}
-	err = json.Unmarshal(newBytes, &newMap)
-	if err != nil {
-		log.Fatalf("Failed new unmarshal: %v", err)
-	}
-	err = json.Unmarshal(originalBytes, &originalMap)
-	if err != nil {
-		log.Fatalf("Failed new unmarshal: %v", err)
-	}
-	return
+	return nil, nil, -1, true
 }
 
-func handleSlice(v, v2 interface{}, diff map[string]interface{}, key string, opts Options) map[string]interface{} {
-	new := reflect.ValueOf(v)
-	orig := reflect.ValueOf(v2)
-	var newSli []interface{}
-	var origSli []interface{}
-	for i := range new.Len() {
-		if new.Kind() == reflect.ValueOf(v).Kind() {
-			newSli = append(newSli, new.Index(i).Interface())
-		} else {
-			newSli = append(newSli, new.Index(i))
-		}
-	}
-	for i := range orig.Len() {
-		if orig.Kind() == reflect.ValueOf(v2).Kind() {
-			origSli = append(origSli, orig.Index(i).Interface())
-		} else {
-			origSli = append(origSli, orig.Index(i))
-		}
-	}
+func handleSlice(origSli, newSli []interface{}, diff map[string]interface{}, key string, opts Options) map[string]interface{} {
 	if opts.AddNewSlice {
 		diff[key] = appendNewSlice(origSli, newSli)
 	} else if opts.ReplaceSlice {
 		diff[key] = newSli
 	} else {
-		diff[key] = appendNewSliceDiffs(origSli, newSli)
+		diff[key] = appendNewSliceDiffs(origSli, newSli, opts)
 	}
 	return diff
 }
 
-func findDiffsForQuery(original, new []byte, idKey string, ignoreEmpty bool) (diff map[string]interface{}, idVal interface{}, err error) {
+// findDiffsForQuery returns the differences of the flat json documents, the value of the id key and the original values of the changed keys.
+func findDiffsForQuery(original, new []byte, idKey string, ignoreEmpty bool, opts Options) (diff, old map[string]interface{}, idVal interface{}, err error) {
 	var originalMap, newMap map[string]interface{}
-	decOrig := json.NewDecoder(bytes.NewReader(original))
-	decOrig.UseNumber()
-	err = decOrig.Decode(&originalMap)
+	err = decodeDocument("original", original, &originalMap, true, opts)
 	if err != nil {
-		return nil, idVal, fmt.Errorf("failed to unmarshal original JSON: %v", err)
+		return nil, nil, idVal, err
 	}
-	decNew := json.NewDecoder(bytes.NewReader(new))
-	decNew.UseNumber()
-	err = decNew.Decode(&newMap)
+	err = decodeDocument("new", new, &newMap, true, opts)
 	if err != nil {
-		return nil, idVal, fmt.Errorf("failed to unmarshal new JSON: %v", err)
+		return nil, nil, idVal, err
 	}
 	idVal = originalMap[idKey]
-	diff, err = simpleMapIterator(originalMap, newMap, ignoreEmpty)
-	if err != nil {
-		return nil, idVal, err
+	diff, err = simpleMapIterator(originalMap, newMap, ignoreEmpty, true, opts)
+	if err = opts.finish(err); err != nil {
+		return nil, nil, idVal, err
+	}
+	old = make(map[string]interface{}, len(diff))
+	for k := range diff {
+		old[k] = queryValue(originalMap[k])
+	}
+	return diff, old, idVal, nil
+}
+
+// queryValue converts json numbers to the int64 or float64 written in queries.
+func queryValue(v interface{}) interface{} {
+	if num, ok := v.(json.Number); ok {
+		if intVal, err := num.Int64(); err == nil {
+			return intVal
+		} else if uintVal, err := strconv.ParseUint(num.String(), 10, 64); err == nil {
+			return uintVal
+		} else if floatVal, err := num.Float64(); err == nil {
+			return floatVal
+		}
+	}
+	return v
+}
+
+func buildQuery(table, condition string, idVal interface{}, set string) (query string) {
+	switch condition {
+	case "id", "Id", "ID":
+		query = buildIDQuery(table, condition, idVal, set)
+	default:
+		query = fmt.Sprintf(`UPDATE "%s" SET %s %v`, table, set, condition)
+	}
+	return query
+}
+
+// buildIDQuery returns the query updating the row whose column has the value.
+func buildIDQuery(table, column string, idVal interface{}, set string) (query string) {
+	switch idVal := idVal.(type) {
+	case string:
+		query = fmt.Sprintf(`UPDATE %v SET %s WHERE %v='%s'`, table, set, column, idVal)
+	case json.Number:
+		query = fmt.Sprintf(`UPDATE %v SET %s WHERE %v=%v`, table, set, column, idVal)
 	}
-	return diff, idVal, nil
+	return query
 }
 
 func buildSetClause(diff map[string]interface{}, rel map[string]string) (set string) {
@@ -291,6 +463,8 @@ func buildSetClause(diff map[string]interface{}, rel map[string]string) (set str
 			}
 			if value, ok := diff[k].(string); ok {
 				sets = append(sets, fmt.Sprintf(`%s='%s'`, attr, value))
+			} else if diff[k] == nil {
+				sets = append(sets, fmt.Sprintf(`%s=NULL`, attr))
 			} else {
 				sets = append(sets, fmt.Sprintf(`%s=%v`, attr, diff[k]))
 			}
@@ -300,6 +474,8 @@ func buildSetClause(diff map[string]interface{}, rel map[string]string) (set str
 		for _, k := range keys {
 			if value, ok := diff[k].(string); ok {
 				sets = append(sets, fmt.Sprintf(`%s='%s'`, k, value))
+			} else if diff[k] == nil {
+				sets = append(sets, fmt.Sprintf(`%s=NULL`, k))
 			} else {
 				sets = append(sets, fmt.Sprintf(`%s=%v`, k, diff[k]))
 			}
@@ -0,0 +1,185 @@
+package gobo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// PatchWithRedisHash returns the HSET and HDEL commands that turn the hash stored at key from the original flat json document
+// into the new one, as argument slices for any client. As with PatchWithQuery, rel maps json keys to field names.
+//
+// Changed and added values are written with one HSET, numbers and booleans as their json text. Removed keys and keys set
+// to null are deleted with one HDEL. Objects and arrays can't be stored in a hash and fail with ErrUnsupportedType.
+func PatchWithRedisHash(original, new []byte, key string, rel map[string]string, optFuncs ...Option) ([][]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	var originalMap, newMap map[string]interface{}
+	if err := decodeDocument("original", original, &originalMap, true, opts); err != nil {
+		return nil, err
+	}
+	if err := decodeDocument("new", new, &newMap, true, opts); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(originalMap)+len(newMap))
+	for k := range originalMap {
+		keys = append(keys, k)
+	}
+	for k := range newMap {
+		if _, found := originalMap[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	hset := []interface{}{"HSET", key}
+	hdel := []interface{}{"HDEL", key}
+	for _, k := range keys {
+		keyPath := joinPath("", k)
+		keyOpts := opts.at(keyPath)
+		if keyOpts.skipped() {
+			continue
+		}
+		field := k
+		if dbField, found := rel[k]; found {
+			field = dbField
+		}
+		origVal, inOrig := originalMap[k]
+		newVal, inNew := newMap[k]
+		if inOrig && inNew {
+			if jsonKind(origVal) != jsonKind(newVal) {
+				if err := checkTypeChange(keyOpts, keyPath, origVal, newVal); err != nil {
+					return nil, err
+				}
+			}
+			if equalValues(keyOpts, origVal, newVal) {
+				continue
+			}
+		}
+		if newVal == nil {
+			if origVal != nil {
+				hdel = append(hdel, field)
+			}
+			continue
+		}
+		value, err := redisValue(newVal)
+		if err != nil {
+			if err := opts.fail(&PathError{Path: keyPath, Old: origVal, New: newVal, Reason: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		hset = append(hset, field, value)
+	}
+	if err := opts.finish(nil); err != nil {
+		return nil, err
+	}
+	var commands [][]interface{}
+	if len(hset) > 2 {
+		commands = append(commands, hset)
+	}
+	if len(hdel) > 2 {
+		commands = append(commands, hdel)
+	}
+	if len(commands) == 0 {
+		return nil, ErrNoDiff
+	}
+	return commands, nil
+}
+
+// redisValue returns the text stored in a hash field for a scalar value.
+func redisValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, jsonKind(v))
+}
+
+// PatchWithRedisJSON returns the RedisJSON commands that turn the document stored at key from the original document
+// into the new one, as argument slices for any client. Paths are JSONPath expressions and values are json.
+//
+// Changed and added values are written with JSON.SET and removed ones with JSON.DEL. Arrays follow the slice options:
+// the new items that aren't in the array are appended with JSON.ARRAPPEND by default, every new item with UseAddNewSlice,
+// and UseReplaceSlice sets the new array.
+func PatchWithRedisJSON(original, new []byte, key string, optFuncs ...Option) ([][]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	var originalMap, newMap map[string]interface{}
+	if err := decodeDocument("original", original, &originalMap, true, opts); err != nil {
+		return nil, err
+	}
+	if err := decodeDocument("new", new, &newMap, true, opts); err != nil {
+		return nil, err
+	}
+	u := redisJSONUpdate{opts: opts, key: key}
+	err := diffUpdate(originalMap, newMap, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.commands) == 0 {
+		return nil, ErrNoDiff
+	}
+	return u.commands, nil
+}
+
+type redisJSONUpdate struct {
+	opts     Options
+	key      string
+	commands [][]interface{}
+}
+
+func (u *redisJSONUpdate) set(path string, segs []string, _, new interface{}) error {
+	return u.write(path, "JSON.SET", segs, new)
+}
+
+func (u *redisJSONUpdate) remove(_ string, segs []string) error {
+	u.commands = append(u.commands, []interface{}{"JSON.DEL", u.key, jsonPath(segs)})
+	return nil
+}
+
+func (u *redisJSONUpdate) array(path string, segs []string, original, new []interface{}, opts Options) error {
+	switch {
+	case opts.AddNewSlice:
+		return u.write(path, "JSON.ARRAPPEND", segs, new...)
+	case opts.ReplaceSlice:
+		return u.write(path, "JSON.SET", segs, new)
+	}
+	if added := addedItems(original, new, opts); len(added) > 0 {
+		return u.write(path, "JSON.ARRAPPEND", segs, added...)
+	}
+	return nil
+}
+
+// write adds the command with the values encoded as json after the path.
+func (u *redisJSONUpdate) write(path, command string, segs []string, values ...interface{}) error {
+	args := []interface{}{command, u.key, jsonPath(segs)}
+	for _, v := range values {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return u.opts.fail(&PathError{Path: path, New: v, Reason: fmt.Errorf("%w: %v", ErrEncoding, err)})
+		}
+		args = append(args, string(encoded))
+	}
+	u.commands = append(u.commands, args)
+	return nil
+}
+
+// jsonPath returns the JSONPath of the keys, using the bracket notation for keys that aren't identifiers.
+func jsonPath(segs []string) string {
+	path := "$"
+	for _, seg := range segs {
+		if identifier.MatchString(seg) {
+			path += "." + seg
+		} else {
+			encoded, _ := json.Marshal(seg)
+			path += "[" + string(encoded) + "]"
+		}
+	}
+	return path
+}
@@ -0,0 +1,46 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedis(t *testing.T) {
+	t.Run("hash", func(t *testing.T) {
+		original := `{"id":1, "name":"John", "age":32, "active":true, "phone":"123", "email":"john@mail.com"}`
+		new := `{"id":1, "name":"Jane", "age":30, "active":false, "email":null, "city":"Rosario"}`
+		commands, err := PatchWithRedisHash([]byte(original), []byte(new), "user:1", map[string]string{"name": "full_name"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]interface{}{
+			{"HSET", "user:1", "active", "false", "age", "30", "city", "Rosario", "full_name", "Jane"},
+			{"HDEL", "user:1", "email", "phone"},
+		}
+		assert.Equal(t, expected, commands)
+	})
+	t.Run("hash errors", func(t *testing.T) {
+		_, err := PatchWithRedisHash([]byte(`{"a":1}`), []byte(`{"a":{"b":1}}`), "k", nil)
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		_, err = PatchWithRedisHash([]byte(`{"a":1, "b":2}`), []byte(`{"a":1, "b":2}`), "k", nil)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("json", func(t *testing.T) {
+		original := `{"name":"John", "address":{"city":"Rosario", "zip code":"2000"}, "tags":["a"], "history":[1], "phone":"123"}`
+		new := `{"name":"Jane", "address":{"city":"Córdoba", "zip code":"2001"}, "tags":["a", "b"], "history":[1, 2]}`
+		commands, err := PatchWithRedisJSON([]byte(original), []byte(new), "user:1", UsePathOptions("/history", UseReplaceSlice()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := [][]interface{}{
+			{"JSON.SET", "user:1", "$.address.city", `"Córdoba"`},
+			{"JSON.SET", "user:1", `$.address["zip code"]`, `"2001"`},
+			{"JSON.SET", "user:1", "$.history", "[1,2]"},
+			{"JSON.SET", "user:1", "$.name", `"Jane"`},
+			{"JSON.DEL", "user:1", "$.phone"},
+			{"JSON.ARRAPPEND", "user:1", "$.tags", `"b"`},
+		}
+		assert.Equal(t, expected, commands)
+	})
+}
@@ -0,0 +1,395 @@
+package gobo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// StreamChanges compares two json documents while reading them and calls emit with every change found,
+// so memory stays proportional to the depth of the documents instead of their size.
+//
+// Objects are walked in lockstep. Keys found in the same order are compared as they come, the rest are buffered
+// until the other document reaches them, which works for documents with keys in different order at the cost of memory.
+// Use UseStreamBuffer to bound the buffered keys. Arrays are compared item by item: extra items of the new document are added
+// and extra items of the original one are removed, all at the index of the first of them so the changes can be applied in order.
+// Numbers are decoded as json.Number.
+//
+// Changes are emitted as they are found: in the order of the documents, and for buffered keys when the other document
+// reaches them or, for keys found in only one document, when their object ends. Buffered values are compared the same way,
+// so the changes don't depend on the order of the keys, only the order they are emitted in does.
+//
+// Changes follow the rules of Changes otherwise, and the options are honored the same way. If emit returns an error,
+// streaming stops and that error is returned. Data after the root value of a document fails with a ParseError
+// once the root values are compared. ErrNoDiff is returned when no change was emitted.
+func StreamChanges(original, new io.Reader, emit func(Change) error, optFuncs ...Option) error {
+	s := streamer{
+		orig: json.NewDecoder(original),
+		new:  json.NewDecoder(new),
+		opts: newOptions(optFuncs),
+		emit: emit,
+	}
+	s.orig.UseNumber()
+	s.new.UseNumber()
+
+	origTok, err := s.orig.Token()
+	if err != nil {
+		return parseError("original", err)
+	}
+	newTok, err := s.new.Token()
+	if err != nil {
+		return parseError("new", err)
+	}
+	err = s.value("", origTok, newTok, 0)
+	if err == nil {
+		err = s.end()
+	}
+	if err = s.opts.finish(err); err != nil {
+		return err
+	}
+	if s.emitted == 0 {
+		return ErrNoDiff
+	}
+	return nil
+}
+
+type streamer struct {
+	orig, new *json.Decoder
+	opts      Options
+	emit      func(Change) error
+	emitted   int
+}
+
+// end fails when a document has data after its root value, as json.Unmarshal does.
+func (s *streamer) end() error {
+	for _, doc := range []struct {
+		name string
+		dec  *json.Decoder
+	}{{"original", s.orig}, {"new", s.new}} {
+		if _, err := doc.dec.Token(); err != io.EOF {
+			if err == nil {
+				err = errors.New("data after the root value")
+			}
+			return parseError(doc.name, err)
+		}
+	}
+	return nil
+}
+
+// value compares the values starting with the given tokens.
+func (s *streamer) value(path string, origTok, newTok json.Token, depth int) error {
+	if err := s.opts.canceled(); err != nil {
+		return err
+	}
+	if s.opts.at(path).Ignore {
+		if err := skipValue(s.orig, origTok); err != nil {
+			return parseError("original", err)
+		}
+		if err := skipValue(s.new, newTok); err != nil {
+			return parseError("new", err)
+		}
+		return nil
+	}
+	if origTok == json.Delim('{') && newTok == json.Delim('{') {
+		return s.object(path, depth+1)
+	}
+	if origTok == json.Delim('[') && newTok == json.Delim('[') {
+		return s.array(path, depth+1)
+	}
+	origVal, err := readValue(s.orig, origTok)
+	if err != nil {
+		return parseError("original", err)
+	}
+	newVal, err := readValue(s.new, newTok)
+	if err != nil {
+		return parseError("new", err)
+	}
+	return s.diff(origVal, newVal, path)
+}
+
+func (s *streamer) object(path string, depth int) error {
+	if err := checkContainer(s.opts, path, depth, 0, 0, nil); err != nil {
+		return err
+	}
+	origPending := make(map[string]interface{})
+	newPending := make(map[string]interface{})
+	origDone, newDone := false, false
+	origKeys, newKeys := 0, 0
+	for !origDone || !newDone {
+		origKey, newKey, err := s.nextKeys(&origDone, &newDone)
+		if err != nil {
+			return err
+		}
+		if origKey != nil {
+			origKeys++
+		}
+		if newKey != nil {
+			newKeys++
+		}
+		if err := checkContainer(s.opts, path, depth, max(origKeys, newKeys), s.opts.MaxKeys, ErrMaxKeys); err != nil {
+			return err
+		}
+		if origKey != nil && newKey != nil && *origKey == *newKey {
+			origTok, err := s.orig.Token()
+			if err != nil {
+				return parseError("original", err)
+			}
+			newTok, err := s.new.Token()
+			if err != nil {
+				return parseError("new", err)
+			}
+			if err := s.value(joinPath(path, *origKey), origTok, newTok, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if origKey != nil {
+			var origVal interface{}
+			if err := s.orig.Decode(&origVal); err != nil {
+				return parseError("original", err)
+			}
+			if newVal, found := newPending[*origKey]; found {
+				delete(newPending, *origKey)
+				if err := s.diff(origVal, newVal, joinPath(path, *origKey)); err != nil {
+					return err
+				}
+			} else {
+				origPending[*origKey] = origVal
+			}
+		}
+		if newKey != nil {
+			var newVal interface{}
+			if err := s.new.Decode(&newVal); err != nil {
+				return parseError("new", err)
+			}
+			if origVal, found := origPending[*newKey]; found {
+				delete(origPending, *newKey)
+				if err := s.diff(origVal, newVal, joinPath(path, *newKey)); err != nil {
+					return err
+				}
+			} else {
+				newPending[*newKey] = newVal
+			}
+		}
+		if buffered := len(origPending) + len(newPending); s.opts.StreamBuffer > 0 && buffered > s.opts.StreamBuffer {
+			return &PathError{Path: path, Reason: fmt.Errorf("%w: %d keys", ErrStreamBuffer, buffered)}
+		}
+	}
+	// keys found only in one of the documents, compared as objects so they are reported sorted
+	return s.diff(origPending, newPending, path)
+}
+
+// nextKeys reads the next key of the objects that didn't end yet. A nil key means the object ended.
+func (s *streamer) nextKeys(origDone, newDone *bool) (origKey, newKey *string, err error) {
+	if !*origDone {
+		tok, err := s.orig.Token()
+		if err != nil {
+			return nil, nil, parseError("original", err)
+		}
+		if key, ok := tok.(string); ok {
+			origKey = &key
+		} else {
+			*origDone = true
+		}
+	}
+	if !*newDone {
+		tok, err := s.new.Token()
+		if err != nil {
+			return nil, nil, parseError("new", err)
+		}
+		if key, ok := tok.(string); ok {
+			newKey = &key
+		} else {
+			*newDone = true
+		}
+	}
+	return origKey, newKey, nil
+}
+
+func (s *streamer) array(path string, depth int) error {
+	if err := checkContainer(s.opts, path, depth, 0, 0, nil); err != nil {
+		return err
+	}
+	removeAt := -1
+	for i := 0; ; i++ {
+		origMore, newMore := s.orig.More(), s.new.More()
+		if !origMore && !newMore {
+			break
+		}
+		if err := checkContainer(s.opts, path, depth, i+1, s.opts.MaxArrayLength, ErrMaxArrayLength); err != nil {
+			return err
+		}
+		itemPath := joinPath(path, strconv.Itoa(i))
+		switch {
+		case origMore && newMore:
+			origTok, err := s.orig.Token()
+			if err != nil {
+				return parseError("original", err)
+			}
+			newTok, err := s.new.Token()
+			if err != nil {
+				return parseError("new", err)
+			}
+			if err := s.value(itemPath, origTok, newTok, depth); err != nil {
+				return err
+			}
+		case origMore:
+			var origVal interface{}
+			if err := s.orig.Decode(&origVal); err != nil {
+				return parseError("original", err)
+			}
+			if removeAt < 0 {
+				removeAt = i
+			}
+			if err := s.send(Change{Path: joinPath(path, strconv.Itoa(removeAt)), Kind: ChangeRemoved, Old: origVal}); err != nil {
+				return err
+			}
+		default:
+			var newVal interface{}
+			if err := s.new.Decode(&newVal); err != nil {
+				return parseError("new", err)
+			}
+			if err := s.send(Change{Path: itemPath, Kind: ChangeAdded, New: newVal}); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := s.orig.Token(); err != nil {
+		return parseError("original", err)
+	}
+	if _, err := s.new.Token(); err != nil {
+		return parseError("new", err)
+	}
+	return nil
+}
+
+// diff compares values already decoded, such as buffered keys, the same way they are compared while streaming.
+func (s *streamer) diff(origVal, newVal interface{}, path string) error {
+	if s.opts.at(path).Ignore {
+		return nil
+	}
+	origMap, origIsMap := origVal.(map[string]interface{})
+	newMap, newIsMap := newVal.(map[string]interface{})
+	if origIsMap && newIsMap {
+		keys := make([]string, 0, len(origMap)+len(newMap))
+		for k := range origMap {
+			keys = append(keys, k)
+		}
+		for k := range newMap {
+			if _, found := origMap[k]; !found {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			origItem, inOrig := origMap[k]
+			newItem, inNew := newMap[k]
+			var err error
+			switch {
+			case inOrig && inNew:
+				err = s.diff(origItem, newItem, joinPath(path, k))
+			case inNew:
+				err = s.send(Change{Path: joinPath(path, k), Kind: ChangeAdded, New: newItem})
+			default:
+				err = s.send(Change{Path: joinPath(path, k), Kind: ChangeRemoved, Old: origItem})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	origSli, origIsSli := origVal.([]interface{})
+	newSli, newIsSli := newVal.([]interface{})
+	if origIsSli && newIsSli {
+		for i := 0; i < len(origSli) || i < len(newSli); i++ {
+			var err error
+			switch {
+			case i < len(origSli) && i < len(newSli):
+				err = s.diff(origSli[i], newSli[i], joinPath(path, strconv.Itoa(i)))
+			case i < len(origSli):
+				err = s.send(Change{Path: joinPath(path, strconv.Itoa(len(newSli))), Kind: ChangeRemoved, Old: origSli[i]})
+			default:
+				err = s.send(Change{Path: joinPath(path, strconv.Itoa(i)), Kind: ChangeAdded, New: newSli[i]})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	changes, err := diffValues(origVal, newVal, s.opts, path)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if err := s.send(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *streamer) send(change Change) error {
+	if s.opts.at(change.Path).skipped() {
+		return nil
+	}
+	s.emitted++
+	return s.emit(change)
+}
+
+// readValue returns the value starting with the token, reading the rest of it when it's an object or an array.
+func readValue(dec *json.Decoder, tok json.Token) (interface{}, error) {
+	switch tok {
+	case json.Delim('{'):
+		m := make(map[string]interface{})
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			m[keyTok.(string)] = v
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		s := make([]interface{}, 0)
+		for dec.More() {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		_, err := dec.Token()
+		return s, err
+	}
+	return tok, nil
+}
+
+// skipValue reads the rest of the value starting with the token without keeping it.
+func skipValue(dec *json.Decoder, tok json.Token) error {
+	if tok != json.Delim('{') && tok != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
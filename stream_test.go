@@ -0,0 +1,142 @@
+package gobo
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func streamChanges(original, new string, optFuncs ...Option) ([]Change, error) {
+	var changes []Change
+	err := StreamChanges(strings.NewReader(original), strings.NewReader(new), func(c Change) error {
+		changes = append(changes, c)
+		return nil
+	}, optFuncs...)
+	return changes, err
+}
+
+func TestStreamChanges(t *testing.T) {
+	t.Run("keys in the same order", func(t *testing.T) {
+		original := `{"name":"John", "age":32, "meta":{"country":"Argentina", "tags":["a", "b"]}, "phone":"123"}`
+		new := `{"name":"Jane", "age":32, "meta":{"country":"Brazil", "tags":["a", "c"]}, "email":"jane@mail.com"}`
+		changes, err := streamChanges(original, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"},
+			{Path: "/meta/country", Kind: ChangeModified, Old: "Argentina", New: "Brazil"},
+			{Path: "/meta/tags/1", Kind: ChangeModified, Old: "b", New: "c"},
+			{Path: "/email", Kind: ChangeAdded, New: "jane@mail.com"},
+			{Path: "/phone", Kind: ChangeRemoved, Old: "123"},
+		}
+		assert.Equal(t, expected, changes)
+	})
+	t.Run("keys in different order", func(t *testing.T) {
+		changes, err := streamChanges(`{"a":1, "b":{"c":true}, "d":"x"}`, `{"d":"y", "b":{"c":false}, "a":1}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/b/c", Kind: ChangeModified, Old: true, New: false},
+			{Path: "/d", Kind: ChangeModified, Old: "x", New: "y"},
+		}
+		assert.Equal(t, expected, changes)
+	})
+	t.Run("same changes whatever the key order", func(t *testing.T) {
+		new := `{"a":[1, 5], "c":2, "d":{"e":[true]}}`
+		expected := []Change{
+			{Path: "/a/1", Kind: ChangeModified, Old: json.Number("2"), New: json.Number("5")},
+			{Path: "/a/2", Kind: ChangeRemoved, Old: json.Number("3")},
+			{Path: "/c", Kind: ChangeModified, Old: json.Number("1"), New: json.Number("2")},
+			{Path: "/d/e/1", Kind: ChangeRemoved, Old: false},
+		}
+		for _, original := range []string{
+			`{"a":[1, 2, 3], "c":1, "d":{"e":[true, false]}}`,
+			`{"d":{"e":[true, false]}, "c":1, "a":[1, 2, 3]}`,
+		} {
+			changes, err := streamChanges(original, new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.ElementsMatch(t, expected, changes, original)
+		}
+	})
+	t.Run("arrays with different length can be applied", func(t *testing.T) {
+		original := `{"tags":["a", "b", "c"], "history":[1]}`
+		new := `{"tags":["a"], "history":[1, 2]}`
+		changes, err := streamChanges(original, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/tags/1", Kind: ChangeRemoved, Old: "b"},
+			{Path: "/tags/1", Kind: ChangeRemoved, Old: "c"},
+			{Path: "/history/1", Kind: ChangeAdded, New: json.Number("2")},
+		}
+		assert.Equal(t, expected, changes)
+		applied, err := Apply([]byte(original), changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.JSONEq(t, new, string(applied))
+	})
+	t.Run("options", func(t *testing.T) {
+		changes, err := streamChanges(`{"name":"John", "etag":{"v":1}, "email":"JOHN@mail.com"}`, `{"name":"Jane", "etag":{"v":2}, "email":"john@mail.com"}`,
+			UseExcludePaths("/etag"), UsePathOptions("/email", UseComparator(CaseInsensitiveComparator())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"}}, changes)
+	})
+	t.Run("type changes", func(t *testing.T) {
+		changes, err := streamChanges(`{"tags":["a"]}`, `{"tags":{"a":true}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []Change{{Path: "/tags", Kind: ChangeTypeChanged, Old: []interface{}{"a"}, New: map[string]interface{}{"a": true}}}, changes)
+		_, err = streamChanges(`{"tags":["a"]}`, `{"tags":{"a":true}}`, UseRejectTypeChange())
+		assert.ErrorIs(t, err, ErrTypeChanged)
+	})
+	t.Run("stream buffer", func(t *testing.T) {
+		_, err := streamChanges(`{"a":1, "b":2, "c":3}`, `{"c":3, "b":2, "a":1}`, UseStreamBuffer(1))
+		assert.ErrorIs(t, err, ErrStreamBuffer)
+	})
+	t.Run("max keys", func(t *testing.T) {
+		changes, err := streamChanges(`{"a":1, "b":2}`, `{"a":1, "b":3}`, UseMaxKeys(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, changes, 1)
+		_, err = streamChanges(`{"a":1, "b":2}`, `{"a":1, "b":3, "c":4}`, UseMaxKeys(2))
+		assert.ErrorIs(t, err, ErrMaxKeys)
+	})
+	t.Run("emit error stops the stream", func(t *testing.T) {
+		errStop := errors.New("stop")
+		calls := 0
+		err := StreamChanges(strings.NewReader(`{"a":1, "b":2}`), strings.NewReader(`{"a":2, "b":3}`), func(Change) error {
+			calls++
+			return errStop
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("invalid document", func(t *testing.T) {
+		_, err := streamChanges(`{"a":1`, `{"a":1}`)
+		var parseErr *ParseError
+		assert.ErrorAs(t, err, &parseErr)
+		_, err = streamChanges(`{"a":1}`, `{"a":2} {"a":3}`)
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, "new", parseErr.Document)
+		}
+		_, err = streamChanges(`{"a":1}x`, `{"a":2}`)
+		assert.ErrorAs(t, err, &parseErr)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		_, err := streamChanges(`{"a":[1, {"b":null}]}`, `{"a":[1, {"b":null}]}`)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}
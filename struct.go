@@ -0,0 +1,231 @@
+package gobo
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// StructDiff returns the differences between two values of the same type without encoding them to json.
+// Fields are named after their `json` tag, fields tagged with "-" and unexported fields are skipped and embedded structs are flattened.
+// Values implementing json.Marshaler or encoding.TextMarshaler, like time.Time, are compared as a whole.
+// Zero values are always included, "omitempty" does not turn a field into an added or removed one.
+//
+// The result follows the rules of Changes, and the Old and New values keep their Go types.
+func StructDiff[T any](old, new T, optFuncs ...Option) ([]Change, error) {
+	opts := newOptions(optFuncs)
+	changes, err := diffValues(structValue(reflect.ValueOf(old), false), structValue(reflect.ValueOf(new), false), opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, ErrNoDiff
+	}
+	return changes, nil
+}
+
+// StructPatchWithQuery works as PatchWithQuery for structs. The relationship between json field names and database attributes
+// is read from the `db` tags, fields without it keep their json name and fields tagged with `db:"-"` are never updated.
+// When 'condition' is the json name or the db attribute of a field, the query updates the row whose attribute has the value
+// of that field, for example "id" or "user_id" for a field tagged `json:"id" db:"user_id"`. Otherwise 'condition' is written
+// as PatchWithQuery does.
+func StructPatchWithQuery[T any](old, new T, table, condition string, ignoreEmpty bool, optFuncs ...Option) (query string, err error) {
+	opts := newOptions(optFuncs)
+
+	if condition == "" {
+		return "", ErrNoCondition
+	}
+	originalMap, ok := structValue(reflect.ValueOf(old), true).(map[string]interface{})
+	if !ok {
+		return "", ErrNotStruct
+	}
+	newMap, ok := structValue(reflect.ValueOf(new), true).(map[string]interface{})
+	if !ok {
+		return "", ErrNotStruct
+	}
+	rel := make(map[string]string)
+	collectColumns(reflect.TypeOf(old), rel)
+	for k, col := range rel {
+		if col == "-" {
+			delete(originalMap, k)
+			delete(newMap, k)
+		}
+	}
+
+	column, idVal, found := structCondition(condition, originalMap, rel)
+	diff, err := simpleMapIterator(originalMap, newMap, ignoreEmpty, false, opts)
+	if err = opts.finish(err); err != nil {
+		return "", err
+	}
+	if found {
+		return buildIDQuery(table, column, idVal, buildSetClause(diff, rel)), nil
+	}
+	return buildQuery(table, condition, nil, buildSetClause(diff, rel)), nil
+}
+
+// structCondition returns the db attribute and the value of the field whose json name or db attribute is the condition.
+// json names are looked up first.
+func structCondition(condition string, originalMap map[string]interface{}, rel map[string]string) (column string, idVal interface{}, found bool) {
+	if idVal, found := originalMap[condition]; found {
+		if col, found := rel[condition]; found {
+			return col, idVal, true
+		}
+		return condition, idVal, true
+	}
+	for k, col := range rel {
+		if col == condition {
+			if idVal, found := originalMap[k]; found {
+				return col, idVal, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// structValue converts the value to the model used by the diff engine: structs and maps become map[string]interface{}
+// and slices []interface{}. With numbers, every number is converted to json.Number and marshalers to their json value,
+// the same way PatchWithQuery decodes them.
+func structValue(rv reflect.Value, numbers bool) interface{} {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if marshaler, ok := rv.Interface().(json.Marshaler); ok {
+		if numbers {
+			if b, err := marshaler.MarshalJSON(); err == nil {
+				if v, err := decodeNumbers(b); err == nil {
+					return v
+				}
+			}
+		}
+		return rv.Interface()
+	}
+	if marshaler, ok := rv.Interface().(encoding.TextMarshaler); ok {
+		if numbers {
+			if b, err := marshaler.MarshalText(); err == nil {
+				return string(b)
+			}
+		}
+		return rv.Interface()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{})
+		structFields(rv, m, numbers)
+		return m
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return rv.Interface()
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = structValue(iter.Value(), numbers)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Interface()
+		}
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, rv.Len())
+		for i := range rv.Len() {
+			s[i] = structValue(rv.Index(i), numbers)
+		}
+		return s
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if numbers {
+			return json.Number(strconv.FormatInt(rv.Int(), 10))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if numbers {
+			return json.Number(strconv.FormatUint(rv.Uint(), 10))
+		}
+	case reflect.Float32, reflect.Float64:
+		if numbers {
+			return json.Number(strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()))
+		}
+	}
+	return rv.Interface()
+}
+
+func structFields(rv reflect.Value, m map[string]interface{}, numbers bool) {
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if name == "" && field.Anonymous {
+			embedded := rv.Field(i)
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				structFields(embedded, m, numbers)
+				continue
+			}
+		}
+		if !field.IsExported() || !rv.Field(i).CanInterface() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		m[name] = structValue(rv.Field(i), numbers)
+	}
+}
+
+// collectColumns fills rel with the json name and the `db` tag of every field that has one.
+func collectColumns(t reflect.Type, rel map[string]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if name == "" && field.Anonymous {
+			collectColumns(field.Type, rel)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if col, found := field.Tag.Lookup("db"); found && col != "" {
+			rel[name] = strings.Split(col, ",")[0]
+		}
+	}
+}
+
+// jsonName returns the name given by the `json` tag, empty if there isn't one, and whether the field is skipped.
+func jsonName(field reflect.StructField) (name string, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	if !field.IsExported() && !field.Anonymous {
+		return "", true
+	}
+	return strings.Split(tag, ",")[0], false
+}
@@ -0,0 +1,110 @@
+package gobo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type audit struct {
+	UpdatedAt time.Time `json:"updated_at" db:"-"`
+}
+
+type address struct {
+	Country string `json:"country"`
+	City    string `json:"city"`
+}
+
+type user struct {
+	ID       int64    `json:"id" db:"user_id"`
+	Name     string   `json:"name" db:"full_name"`
+	LastName string   `json:"last_name" db:"last_name"`
+	Age      int      `json:"age,omitempty"`
+	Active   bool     `json:"active"`
+	Tags     []string `json:"tags" db:"-"`
+	Address  *address `json:"address" db:"-"`
+	password string
+	Internal string `json:"-"`
+	audit
+}
+
+func TestStructDiff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	old := user{ID: 1, Name: "John", LastName: "Doe", Age: 32, Tags: []string{"a"}, Address: &address{Country: "Argentina", City: "Rosario"}, password: "123", audit: audit{UpdatedAt: now}}
+	t.Run("differences keep go types", func(t *testing.T) {
+		new := old
+		new.Name = "Jane"
+		new.Age = 0
+		new.Active = true
+		new.Address = &address{Country: "Brazil", City: "Rosario"}
+		new.password = "456"
+		new.Internal = "changed"
+		new.UpdatedAt = now.Add(time.Hour)
+		changes, err := StructDiff(old, new)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []Change{
+			{Path: "/active", Kind: ChangeModified, Old: false, New: true},
+			{Path: "/address/country", Kind: ChangeModified, Old: "Argentina", New: "Brazil"},
+			{Path: "/age", Kind: ChangeModified, Old: 32, New: 0},
+			{Path: "/name", Kind: ChangeModified, Old: "John", New: "Jane"},
+			{Path: "/updated_at", Kind: ChangeModified, Old: now, New: now.Add(time.Hour)},
+		}
+		assert.Equal(t, expected, changes)
+	})
+	t.Run("pointers and no differences", func(t *testing.T) {
+		new := old
+		_, err := StructDiff(&old, &new)
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("query with db tags", func(t *testing.T) {
+		new := old
+		new.Name = "Jane"
+		new.Age = 30
+		new.Active = true
+		new.Tags = []string{"b"}
+		new.UpdatedAt = now.Add(time.Hour)
+		query, err := StructPatchWithQuery(old, new, "users", "id", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET active=true, age=30, full_name='Jane' WHERE user_id=1`, query)
+
+		query, err = StructPatchWithQuery(old, new, "users", "user_id", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE users SET active=true, age=30, full_name='Jane' WHERE user_id=1`, query)
+	})
+	t.Run("condition from db tag", func(t *testing.T) {
+		type project struct {
+			ProjectID string `json:"projectId" db:"id"`
+			Name      string `json:"name"`
+		}
+		query, err := StructPatchWithQuery(project{"abc", "res-man"}, project{"abc", "resources manager"}, "project", "id", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE project SET name='resources manager' WHERE id='abc'`, query)
+	})
+	t.Run("fields with equal values", func(t *testing.T) {
+		type score struct {
+			ID    int    `json:"id"`
+			Score int    `json:"score"`
+			Big   uint64 `json:"big"`
+			Note  string `json:"note"`
+			Tag   string `json:"tag"`
+		}
+		query, err := StructPatchWithQuery(score{ID: 1}, score{ID: 1, Score: 1, Big: 1 << 63}, "scores", "id", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, `UPDATE scores SET big=9223372036854775808, score=1 WHERE id=1`, query)
+	})
+	t.Run("not a struct", func(t *testing.T) {
+		_, err := StructPatchWithQuery(1, 2, "users", "id", true)
+		assert.Equal(t, ErrNotStruct, err)
+	})
+}
@@ -0,0 +1,267 @@
+package gobo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"unsafe"
+)
+
+// Patch holds the differences between two values of type T computed by Diff.
+type Patch[T any] struct {
+	old, new T
+	changes  []Change
+	opts     Options
+}
+
+// Diff returns the typed patch between two values, see StructDiff for how they are compared.
+// When there are no differences the empty patch is returned along with ErrNoDiff.
+func Diff[T any](old, new T, optFuncs ...Option) (Patch[T], error) {
+	p := Patch[T]{old: old, new: new, opts: newOptions(optFuncs)}
+	changes, err := diffValues(structValue(reflect.ValueOf(old), false), structValue(reflect.ValueOf(new), false), p.opts, "")
+	if err = p.opts.finish(err); err != nil {
+		return p, err
+	}
+	p.changes = changes
+	if len(p.changes) == 0 {
+		return p, ErrNoDiff
+	}
+	return p, nil
+}
+
+// Changes returns the differences of the patch.
+func (p Patch[T]) Changes() []Change {
+	return p.changes
+}
+
+// Changed reports whether the value returned by the selector changed, for example:
+//
+//	patch.Changed(func(u *User) any { return &u.Email })
+//
+// When the selector returns a pointer to a field of T, the field path is checked against the changes of the patch,
+// so the options given to Diff are honored. Otherwise the values selected from the old and the new T are compared.
+func (p Patch[T]) Changed(selector func(*T) any) bool {
+	old, new := p.old, p.new
+	oldSel, newSel := selector(&old), selector(&new)
+	if path, ok := selectedPath(&old, oldSel); ok {
+		for _, change := range p.changes {
+			if change.Path == path || isAncestor(path, change.Path) || isAncestor(change.Path, path) {
+				return true
+			}
+		}
+		return false
+	}
+	changes, err := diffValues(structValue(reflect.ValueOf(oldSel), false), structValue(reflect.ValueOf(newSel), false), p.opts, "")
+	return err != nil || len(changes) > 0
+}
+
+// Apply applies the changes of the patch to the target, leaving the rest of its fields untouched.
+func (p Patch[T]) Apply(target *T) error {
+	rv := reflect.ValueOf(target).Elem()
+	for _, change := range p.changes {
+		if err := applyTyped(rv, splitPath(change.Path), change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectedPath returns the JSON Pointer of the field pointed by the selected value when it's placed inside base.
+func selectedPath[T any](base *T, selected any) (string, bool) {
+	sel := reflect.ValueOf(selected)
+	if !sel.IsValid() || sel.Kind() != reflect.Pointer || sel.IsNil() {
+		return "", false
+	}
+	start := uintptr(unsafe.Pointer(base))
+	addr := sel.Pointer()
+	if addr < start || addr >= start+unsafe.Sizeof(*base) {
+		return "", false
+	}
+	return fieldPath(reflect.TypeFor[T](), addr-start, sel.Type().Elem(), "")
+}
+
+func fieldPath(t reflect.Type, offset uintptr, target reflect.Type, path string) (string, bool) {
+	if offset == 0 && t == target {
+		return path, true
+	}
+	if t.Kind() != reflect.Struct {
+		return "", false
+	}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if offset < field.Offset || offset >= field.Offset+field.Type.Size() {
+			continue
+		}
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		fieldPathPrefix := path
+		if name != "" || !field.Anonymous || field.Type.Kind() != reflect.Struct {
+			if name == "" {
+				name = field.Name
+			}
+			fieldPathPrefix = joinPath(path, name)
+		}
+		if found, ok := fieldPath(field.Type, offset-field.Offset, target, fieldPathPrefix); ok {
+			return found, true
+		}
+	}
+	return "", false
+}
+
+func applyTyped(rv reflect.Value, segs []string, change Change) error {
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if len(segs) == 0 {
+		if change.Kind == ChangeRemoved {
+			rv.SetZero()
+			return nil
+		}
+		return setValue(rv, change.New)
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		field, found := fieldByJSONName(rv, segs[0])
+		if !found {
+			break
+		}
+		return applyTyped(field, segs[1:], change)
+	case reflect.Map:
+		key := reflect.ValueOf(segs[0]).Convert(rv.Type().Key())
+		if len(segs) == 1 && change.Kind == ChangeRemoved {
+			rv.SetMapIndex(key, reflect.Value{})
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.MakeMap(rv.Type()))
+		}
+		elem := reflect.New(rv.Type().Elem()).Elem()
+		if current := rv.MapIndex(key); current.IsValid() {
+			elem.Set(current)
+		} else if len(segs) > 1 {
+			break
+		}
+		if err := applyTyped(elem, segs[1:], change); err != nil {
+			return err
+		}
+		rv.SetMapIndex(key, elem)
+		return nil
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(segs[0])
+		if err != nil || i < 0 || i >= rv.Len() {
+			break
+		}
+		return applyTyped(rv.Index(i), segs[1:], change)
+	}
+	return &PathError{Path: change.Path, New: change.New, Reason: ErrPathNotFound}
+}
+
+func fieldByJSONName(rv reflect.Value, name string) (reflect.Value, bool) {
+	for i := range rv.NumField() {
+		field := rv.Type().Field(i)
+		fieldName, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		if fieldName == "" && field.Anonymous {
+			embedded := rv.Field(i)
+			if embedded.Kind() == reflect.Pointer {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if found, ok := fieldByJSONName(embedded, name); ok {
+					return found, true
+				}
+				continue
+			}
+		}
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		if fieldName == name && rv.Field(i).CanSet() {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setValue assigns a value of the diff model to dst converting maps and slices back to the destination type.
+func setValue(dst reflect.Value, v interface{}) error {
+	if v == nil {
+		dst.SetZero()
+		return nil
+	}
+	src := reflect.ValueOf(v)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	switch dst.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		if err := setValue(elem.Elem(), v); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	case reflect.Struct:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, val := range m {
+				field, found := fieldByJSONName(dst, k)
+				if !found {
+					continue
+				}
+				if err := setValue(field, val); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			out := reflect.MakeMapWithSize(dst.Type(), len(m))
+			for k, val := range m {
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := setValue(elem, val); err != nil {
+					return err
+				}
+				out.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+			}
+			dst.Set(out)
+			return nil
+		}
+	case reflect.Slice:
+		if s, ok := v.([]interface{}); ok {
+			out := reflect.MakeSlice(dst.Type(), len(s), len(s))
+			for i, val := range s {
+				if err := setValue(out.Index(i), val); err != nil {
+					return err
+				}
+			}
+			dst.Set(out)
+			return nil
+		}
+	case reflect.Array:
+		if s, ok := v.([]interface{}); ok && len(s) == dst.Len() {
+			for i, val := range s {
+				if err := setValue(dst.Index(i), val); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if src.Type().ConvertibleTo(dst.Type()) && src.Kind() != reflect.String {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot assign %T to %s", v, dst.Type())
+}
@@ -0,0 +1,67 @@
+package gobo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type account struct {
+	Email    string            `json:"email"`
+	Name     string            `json:"name"`
+	Roles    []string          `json:"roles"`
+	Settings map[string]string `json:"settings"`
+	Address  address           `json:"address"`
+	Backup   *address          `json:"backup"`
+}
+
+func TestTypedDiff(t *testing.T) {
+	old := account{
+		Email:    "john@mail.com",
+		Name:     "John",
+		Roles:    []string{"admin"},
+		Settings: map[string]string{"theme": "dark", "lang": "es"},
+		Address:  address{Country: "Argentina", City: "Rosario"},
+	}
+	new := account{
+		Email:    "john@mail.com",
+		Name:     "Jane",
+		Roles:    []string{"admin", "editor"},
+		Settings: map[string]string{"theme": "light", "tz": "UTC"},
+		Address:  address{Country: "Brazil", City: "Rosario"},
+		Backup:   &address{Country: "Chile"},
+	}
+	patch, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("changed fields", func(t *testing.T) {
+		assert.False(t, patch.Changed(func(a *account) any { return &a.Email }))
+		assert.True(t, patch.Changed(func(a *account) any { return &a.Name }))
+		assert.True(t, patch.Changed(func(a *account) any { return &a.Address }))
+		assert.True(t, patch.Changed(func(a *account) any { return &a.Address.Country }))
+		assert.False(t, patch.Changed(func(a *account) any { return &a.Address.City }))
+		assert.True(t, patch.Changed(func(a *account) any { return a.Settings["theme"] }))
+		assert.False(t, patch.Changed(func(a *account) any { return len(a.Email) }))
+	})
+	t.Run("options are honored", func(t *testing.T) {
+		patch, err := Diff(old, new, UseExcludePaths("/name"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.False(t, patch.Changed(func(a *account) any { return &a.Name }))
+	})
+	t.Run("apply", func(t *testing.T) {
+		target := old
+		target.Settings = map[string]string{"theme": "dark", "lang": "es"}
+		if err := patch.Apply(&target); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, new, target)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		patch, err := Diff(old, old)
+		assert.Equal(t, ErrNoDiff, err)
+		assert.Empty(t, patch.Changes())
+	})
+}
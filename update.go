@@ -0,0 +1,195 @@
+package gobo
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// identifier matches the keys written without brackets in JSONPath, and the names accepted by GraphQL.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// updateWriter writes the changes found by diffUpdate in the update format of a database.
+// Values are given in their json form and segs are the keys and indexes leading to them.
+type updateWriter interface {
+	// set writes a value added or changed at path. original is nil for added values.
+	set(path string, segs []string, original, new interface{}) error
+	remove(path string, segs []string) error
+	// array writes the change of an array according to the slice options of its path.
+	array(path string, segs []string, original, new []interface{}, opts Options) error
+}
+
+// diffUpdate walks both objects like diffObjects and gives the changes to the writer. Arrays are always written whole.
+func diffUpdate(original, new map[string]interface{}, opts Options, w updateWriter, path string, segs []string) error {
+	keys := make([]string, 0, len(original)+len(new))
+	for k := range original {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, found := original[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		keyPath := joinPath(path, k)
+		keyOpts := opts.at(keyPath)
+		if keyOpts.Ignore {
+			continue
+		}
+		keySegs := append(segs[:len(segs):len(segs)], k)
+		origVal, inOrig := original[k]
+		newVal, inNew := new[k]
+		var err error
+		switch {
+		case inOrig && inNew:
+			err = diffUpdateValue(origVal, newVal, opts, w, keyPath, keySegs)
+		case keyOpts.partial:
+		case inNew:
+			err = w.set(keyPath, keySegs, nil, newVal)
+		default:
+			err = w.remove(keyPath, keySegs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func diffUpdateValue(original, new interface{}, opts Options, w updateWriter, path string, segs []string) error {
+	valOpts := opts.at(path)
+	if jsonKind(original) != jsonKind(new) {
+		if valOpts.partial {
+			return nil
+		}
+		if err := checkTypeChange(valOpts, path, original, new); err != nil {
+			return err
+		}
+		return w.set(path, segs, original, new)
+	}
+	switch new := new.(type) {
+	case map[string]interface{}:
+		return diffUpdate(original.(map[string]interface{}), new, opts, w, path, segs)
+	case []interface{}:
+		original := original.([]interface{})
+		if _, _, _, equal := equalSlices(original, new, valOpts); valOpts.partial || equal {
+			return nil
+		}
+		return w.array(path, segs, original, new, valOpts)
+	}
+	if valOpts.partial || equalValues(valOpts, original, new) {
+		return nil
+	}
+	return w.set(path, segs, original, new)
+}
+
+// removedItems returns the items of the original array missing in the new one, when the new array is the original one
+// without them. Items equal to a removed one must be removed too, since databases remove every match.
+func removedItems(original, new []interface{}, opts Options) ([]interface{}, bool) {
+	var removed, kept []interface{}
+	j := 0
+	for _, item := range original {
+		if j < len(new) && equalValues(opts, item, new[j]) {
+			kept = append(kept, item)
+			j++
+		} else {
+			removed = append(removed, item)
+		}
+	}
+	if j < len(new) {
+		return nil, false
+	}
+	for _, item := range kept {
+		for _, r := range removed {
+			if equalValues(opts, item, r) {
+				return nil, false
+			}
+		}
+	}
+	return removed, true
+}
+
+// addedItems returns the items of the new array missing in the original one, as the default slice strategy adds them.
+func addedItems(original, new []interface{}, opts Options) []interface{} {
+	return appendNewSliceDiffs(original, new, opts)[len(original):]
+}
+
+// mergePatch builds a nested object with the values that changed, as a JSON merge patch: removed values are null
+// and arrays are written whole following the slice options. Keys are renamed with rel, if given,
+// and with numbers, json.Number values are converted to int64 or float64.
+type mergePatch struct {
+	rel     map[string]string
+	numbers bool
+	patch   map[string]interface{}
+}
+
+func (u *mergePatch) set(path string, segs []string, _, new interface{}) error {
+	parent, parentPath := u.patch, ""
+	for _, seg := range segs[:len(segs)-1] {
+		parentPath = joinPath(parentPath, seg)
+		name := u.name(parentPath, seg)
+		child, ok := parent[name].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			parent[name] = child
+		}
+		parent = child
+	}
+	parent[u.name(path, segs[len(segs)-1])] = u.value(path, new)
+	return nil
+}
+
+func (u *mergePatch) remove(path string, segs []string) error {
+	return u.set(path, segs, nil, nil)
+}
+
+func (u *mergePatch) array(path string, segs []string, original, new []interface{}, opts Options) error {
+	switch {
+	case opts.AddNewSlice:
+		return u.set(path, segs, nil, appendNewSlice(original, new))
+	case opts.ReplaceSlice:
+		return u.set(path, segs, nil, new)
+	}
+	// the default strategy keeps the original items, so an array that only lost items is left out
+	added := addedItems(original, new, opts)
+	if len(added) == 0 {
+		return nil
+	}
+	return u.set(path, segs, nil, appendNewSlice(original, added))
+}
+
+// name returns the name given by rel to the key at path.
+func (u *mergePatch) name(path, k string) string {
+	if name, found := u.rel[path]; found {
+		return name
+	}
+	if name, found := u.rel[k]; found {
+		return name
+	}
+	return k
+}
+
+// value renames the keys of the objects of the value at path and converts its numbers.
+func (u *mergePatch) value(path string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			keyPath := joinPath(path, k)
+			m[u.name(keyPath, k)] = u.value(keyPath, val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, item := range v {
+			s[i] = u.value(joinPath(path, strconv.Itoa(i)), item)
+		}
+		return s
+	}
+	if u.numbers {
+		return queryValue(v)
+	}
+	return v
+}
@@ -0,0 +1,366 @@
+package gobo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLDiff works as JSONDiff for YAML documents, with the same options. The root of both documents must be a mapping.
+//
+// Values are converted to their json form: integers and floats are json.Number, as in CBORDiff,
+// so integers of any size keep their value. Timestamps are kept as the strings written in the document so TimeComparator
+// can compare them, and keys that aren't strings, such as numbers or booleans, are used as written.
+// Anchors and merge keys ("<<") are resolved. Input with more than one document fails with a ParseError.
+func YAMLDiff(original, new []byte, optFuncs ...Option) (map[string]interface{}, error) {
+	opts := newOptions(optFuncs)
+
+	originalMap, newMap, _, err := decodeYAMLDocuments(original, new, opts)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := iterateMaps(originalMap, newMap, opts, "")
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// YAMLPatch returns the differences between the YAML documents as a YAML merge patch: changed values keep their nesting,
+// removed values are null and a changed sequence is written whole, as JSONDiff returns it with the slice options.
+// Keys are written in the order they appear in the new document, and keys that weren't strings and timestamps keep their tag.
+func YAMLPatch(original, new []byte, optFuncs ...Option) ([]byte, error) {
+	opts := newOptions(optFuncs)
+
+	originalMap, newMap, newDoc, err := decodeYAMLDocuments(original, new, opts)
+	if err != nil {
+		return nil, err
+	}
+	u := mergePatch{patch: make(map[string]interface{})}
+	err = diffUpdate(originalMap, newMap, opts, &u, "", nil)
+	if err = opts.finish(err); err != nil {
+		return nil, err
+	}
+	if len(u.patch) == 0 {
+		return nil, ErrNoDiff
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(newDoc.node(u.patch, "")); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeYAMLDocuments decodes both documents, whose roots must be mappings.
+func decodeYAMLDocuments(original, new []byte, opts Options) (originalMap, newMap map[string]interface{}, newDoc *yamlDocument, err error) {
+	origDoc, err := decodeYAML("original", original, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	newDoc, err = decodeYAML("new", new, opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	originalMap, ok := origDoc.value.(map[string]interface{})
+	if !ok {
+		return nil, nil, nil, &ParseError{Document: "original", Format: "yaml", Err: fmt.Errorf("root is %s, not an object", jsonKind(origDoc.value))}
+	}
+	newMap, ok = newDoc.value.(map[string]interface{})
+	if !ok {
+		return nil, nil, nil, &ParseError{Document: "new", Format: "yaml", Err: fmt.Errorf("root is %s, not an object", jsonKind(newDoc.value))}
+	}
+	return originalMap, newMap, newDoc, nil
+}
+
+// yamlDocument is a YAML document converted to its json form, with what's needed to write values back,
+// by JSON Pointer: the position of each key in its mapping, the tag of the keys that weren't strings
+// and the strings that were timestamps.
+type yamlDocument struct {
+	value      interface{}
+	order      map[string]int
+	keyTags    map[string]string
+	timestamps map[string]bool
+
+	// opts are the limits enforced while converting, nodes counts the values converted so far, aliases included,
+	// and expanding holds the anchors being converted to stop aliases that contain themselves.
+	opts      Options
+	nodes     int
+	maxNodes  int
+	expanding map[*yaml.Node]bool
+}
+
+// yamlAliasRatio is how many values a document can expand to per byte when no MaxBytes is given.
+// Without aliases a document can't hold more values than bytes, so only alias bombs reach it.
+const yamlAliasRatio = 100
+
+// decodeYAML decodes the YAML document enforcing the limits of the options, as decodeDocument does for json.
+func decodeYAML(name string, data []byte, opts Options) (*yamlDocument, error) {
+	if opts.MaxBytes > 0 && len(data) > opts.MaxBytes {
+		return nil, &ParseError{Document: name, Format: "yaml", Err: fmt.Errorf("%w: %d bytes of %d", ErrMaxBytes, len(data), opts.MaxBytes)}
+	}
+	if err := opts.canceled(); err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(data, opts)
+	if err != nil {
+		return nil, &ParseError{Document: name, Format: "yaml", Err: err}
+	}
+	return doc, nil
+}
+
+// parseYAML converts the YAML document to its json form. The depth, keys and array length limits of the options
+// are checked while converting and aliases can't expand the document to more values than MaxBytes,
+// or than yamlAliasRatio per byte when it isn't given.
+func parseYAML(data []byte, opts Options) (*yamlDocument, error) {
+	var root yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&root); err != nil && err != io.EOF {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		if err == nil {
+			err = errors.New("more than one document")
+		}
+		return nil, err
+	}
+	doc := &yamlDocument{
+		order:      make(map[string]int),
+		keyTags:    make(map[string]string),
+		timestamps: make(map[string]bool),
+		opts:       opts,
+		maxNodes:   opts.MaxBytes,
+		expanding:  make(map[*yaml.Node]bool),
+	}
+	if doc.maxNodes <= 0 {
+		doc.maxNodes = yamlAliasRatio * max(len(data), 1)
+	}
+	value, err := doc.convert(&root, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	doc.value = value
+	return doc, nil
+}
+
+// convert returns the json form of the node at path, whose parent is at depth.
+func (d *yamlDocument) convert(n *yaml.Node, path string, depth int) (interface{}, error) {
+	if n.Kind != yaml.DocumentNode && n.Kind != yaml.AliasNode {
+		if d.nodes++; d.nodes > d.maxNodes {
+			return nil, &PathError{Path: path, Reason: fmt.Errorf("%w: aliases expand to more than %d values", ErrMaxBytes, d.maxNodes)}
+		}
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return d.convert(n.Content[0], path, depth)
+	case yaml.AliasNode:
+		if d.expanding[n.Alias] {
+			return nil, &PathError{Path: path, Reason: fmt.Errorf("%w: alias *%s contains itself", ErrUnsupportedType, n.Value)}
+		}
+		d.expanding[n.Alias] = true
+		defer delete(d.expanding, n.Alias)
+		return d.convert(n.Alias, path, depth)
+	case yaml.SequenceNode:
+		if err := checkContainer(d.opts, path, depth+1, len(n.Content), d.opts.MaxArrayLength, ErrMaxArrayLength); err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, 0, len(n.Content))
+		for i, item := range n.Content {
+			v, err := d.convert(item, joinPath(path, strconv.Itoa(i)), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case yaml.MappingNode:
+		if err := checkContainer(d.opts, path, depth+1, len(n.Content)/2, d.opts.MaxKeys, ErrMaxKeys); err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(n.Content)/2)
+		var merged []*yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			keyNode, valNode := n.Content[i], n.Content[i+1]
+			if keyNode.ShortTag() == "!!merge" {
+				merged = append(merged, valNode)
+				continue
+			}
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, &PathError{Path: path, Reason: fmt.Errorf("%w: %s key", ErrUnsupportedType, yamlKind(keyNode))}
+			}
+			key, keyPath := keyNode.Value, joinPath(path, keyNode.Value)
+			if _, found := d.order[keyPath]; !found {
+				d.order[keyPath] = i / 2
+			}
+			if tag := keyNode.ShortTag(); tag != "!!str" {
+				d.keyTags[keyPath] = tag
+			}
+			v, err := d.convert(valNode, keyPath, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		// keys written in the mapping win over the merged ones
+		for _, mergedNode := range merged {
+			if err := d.merge(m, mergedNode, path, depth); err != nil {
+				return nil, err
+			}
+		}
+		if len(merged) > 0 {
+			if err := checkContainer(d.opts, path, depth+1, len(m), d.opts.MaxKeys, ErrMaxKeys); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	switch n.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, &PathError{Path: path, Reason: err}
+		}
+		return b, nil
+	case "!!int":
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), nil
+		}
+		// integers beyond int64 are parsed the same way yaml.v3 parses the others
+		if b, ok := new(big.Int).SetString(n.Value, 0); ok {
+			return json.Number(b.String()), nil
+		}
+		return nil, &PathError{Path: path, New: n.Value, Reason: fmt.Errorf("%w: %s", ErrInvalidNumber, n.Value)}
+	case "!!float":
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, &PathError{Path: path, New: n.Value, Reason: fmt.Errorf("%w: %v", ErrInvalidNumber, err)}
+		}
+		return floatNumber(f), nil
+	case "!!timestamp":
+		d.timestamps[path] = true
+	}
+	return n.Value, nil
+}
+
+// merge adds to m the keys of the mappings referenced by a merge key that m doesn't have.
+func (d *yamlDocument) merge(m map[string]interface{}, n *yaml.Node, path string, depth int) error {
+	if n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			if err := d.merge(m, item, path, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	v, err := d.convert(n, path, depth)
+	if err != nil {
+		return err
+	}
+	mergedMap, ok := v.(map[string]interface{})
+	if !ok {
+		return &PathError{Path: path, Reason: fmt.Errorf("%w: merge of %s", ErrUnsupportedType, jsonKind(v))}
+	}
+	for k, v := range mergedMap {
+		if _, found := m[k]; !found {
+			m[k] = v
+		}
+	}
+	return nil
+}
+
+// node returns the YAML node of the value at path in its json form, with the keys in the order of the document.
+func (d *yamlDocument) node(v interface{}, path string) *yaml.Node {
+	switch v := v.(type) {
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eEIN") {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: v.String()}
+		}
+		f, err := v.Float64()
+		switch {
+		case err != nil:
+		case math.IsInf(f, 1):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: ".inf"}
+		case math.IsInf(f, -1):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: "-.inf"}
+		case math.IsNaN(f):
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: ".nan"}
+		default:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: v.String()}
+		}
+	case string:
+		if d.timestamps[path] {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v}
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v}
+	case []interface{}:
+		n := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for i, item := range v {
+			n.Content = append(n.Content, d.node(item, joinPath(path, strconv.Itoa(i))))
+		}
+		return n
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			iPos, iFound := d.order[joinPath(path, keys[i])]
+			jPos, jFound := d.order[joinPath(path, keys[j])]
+			if iFound != jFound {
+				return iFound
+			}
+			if iPos != jPos {
+				return iPos < jPos
+			}
+			return keys[i] < keys[j]
+		})
+		n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range keys {
+			keyPath, tag := joinPath(path, k), "!!str"
+			if keyTag, found := d.keyTags[keyPath]; found {
+				tag = keyTag
+			}
+			n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: k}, d.node(v[k], keyPath))
+		}
+		return n
+	}
+	n := &yaml.Node{}
+	if err := n.Encode(v); err != nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: fmt.Sprint(v)}
+	}
+	return n
+}
+
+func yamlKind(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "sequence"
+	}
+	return "scalar"
+}
@@ -0,0 +1,141 @@
+package gobo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestYAMLDiff(t *testing.T) {
+	original := `
+name: John
+age: 32
+created: 2024-01-01T10:00:00Z
+tags: [a, b]
+meta:
+  country: Argentina
+  1: one
+`
+	new := `
+name: Jane
+age: 30
+created: 2024-01-02T10:00:00Z
+tags: [a, b, c]
+meta:
+  country: Argentina
+  1: uno
+`
+	t.Run("differences", func(t *testing.T) {
+		diff, err := YAMLDiff([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]interface{}{
+			"name":    "Jane",
+			"age":     json.Number("30"),
+			"created": "2024-01-02T10:00:00Z",
+			"tags":    []interface{}{"a", "b", "c"},
+			"1":       "uno",
+		}
+		assert.Equal(t, expected, diff)
+	})
+	t.Run("patch keeps the nesting, the order and the tags", func(t *testing.T) {
+		patch, err := YAMLPatch([]byte(original), []byte(new))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `name: Jane
+age: 30
+created: 2024-01-02T10:00:00Z
+tags:
+  - a
+  - b
+  - c
+meta:
+  1: uno
+`
+		assert.Equal(t, expected, string(patch))
+	})
+	t.Run("patch with removed keys", func(t *testing.T) {
+		patch, err := YAMLPatch([]byte("a: 1\nb: {c: 1, d: 2}\n"), []byte("b: {d: 3}\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "b:\n  d: 3\n  c: null\na: null\n", string(patch))
+		_, err = YAMLPatch([]byte(original), []byte(original))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+	t.Run("options", func(t *testing.T) {
+		diff, err := YAMLDiff([]byte(original), []byte(new), UseExcludePaths("/tags", "/meta"),
+			UseComparator(TimeComparator(time.DateOnly)), UsePathOptions("/age", UseComparator(EpsilonComparator(5))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"name": "Jane", "created": "2024-01-02T10:00:00Z"}, diff)
+	})
+	t.Run("anchors and merge keys", func(t *testing.T) {
+		original := "base: &base\n  region: us\n  size: 1\nprod:\n  <<: *base\n  size: 3\n"
+		new := "base: &base\n  region: eu\n  size: 1\nprod:\n  <<: *base\n  size: 3\n"
+		diff, err := YAMLDiff([]byte(original), []byte(new), UseReplaceSlice())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"region": "eu"}, diff)
+	})
+	t.Run("invalid documents", func(t *testing.T) {
+		var parseErr *ParseError
+		_, err := YAMLDiff([]byte("a: [1"), []byte("a: 1"))
+		assert.ErrorAs(t, err, &parseErr)
+		assert.Contains(t, err.Error(), "original yaml-encoded parse failed")
+		_, err = YAMLDiff([]byte("- a"), []byte("a: 1"))
+		assert.ErrorAs(t, err, &parseErr)
+		assert.EqualError(t, err, "original yaml-encoded parse failed: root is array, not an object")
+		_, err = YAMLDiff([]byte("a: 1"), []byte("? [a]\n: 1\n"))
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		_, err = YAMLDiff([]byte("a: 1\n---\na: 2\n"), []byte("a: 1"))
+		assert.ErrorAs(t, err, &parseErr)
+		assert.Contains(t, err.Error(), "more than one document")
+	})
+	t.Run("big numbers", func(t *testing.T) {
+		diff, err := YAMLDiff([]byte("id: 12345678901234567890\nratio: 0.5\n"), []byte("id: 12345678901234567891\nratio: 0.50\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]interface{}{"id": json.Number("12345678901234567891")}, diff)
+		patch, err := YAMLPatch([]byte("id: 1\nratio: 0.5\n"), []byte("id: 0x12345678901234567\nratio: .inf\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "id: 0x12345678901234567\nratio: .inf\n", string(patch))
+	})
+	t.Run("limits", func(t *testing.T) {
+		// 10^7 values from 7 levels of 10 aliases
+		bomb := "a: &a [x, x, x, x, x, x, x, x, x, x]\n"
+		levels := "abcdefg"
+		for i := 1; i < len(levels); i++ {
+			aliases := strings.TrimSuffix(strings.Repeat("*"+levels[i-1:i]+", ", 10), ", ")
+			bomb += levels[i:i+1] + ": &" + levels[i:i+1] + " [" + aliases + "]\n"
+		}
+		_, err := YAMLDiff([]byte(bomb), []byte("a: 1"))
+		assert.ErrorIs(t, err, ErrMaxBytes)
+		_, err = Changes([]byte(bomb), []byte("a: 1"), UseFormat("yaml"))
+		assert.ErrorIs(t, err, ErrMaxBytes)
+		_, err = YAMLDiff([]byte(bomb), []byte("a: 1"), UseMaxBytes(1024))
+		assert.ErrorIs(t, err, ErrMaxBytes)
+		_, err = YAMLDiff([]byte("a: &a\n  b: *a\n"), []byte("a: 1"))
+		assert.ErrorIs(t, err, ErrUnsupportedType)
+		_, err = YAMLDiff([]byte("a:\n  b:\n    c: 1\n"), []byte("a: 1"), UseMaxDepth(2))
+		assert.ErrorIs(t, err, ErrMaxDepth)
+		_, err = YAMLDiff([]byte("base: &base {a: 1, b: 2}\nc:\n  <<: *base\n  d: 3\n"), []byte("a: 1"), UseMaxKeys(2))
+		assert.ErrorIs(t, err, ErrMaxKeys)
+		_, err = YAMLDiff([]byte("a: [1, 2, 3]"), []byte("a: 1"), UseMaxArrayLength(2))
+		assert.ErrorIs(t, err, ErrMaxArrayLength)
+	})
+	t.Run("no differences", func(t *testing.T) {
+		_, err := YAMLDiff([]byte(original), []byte(original))
+		assert.Equal(t, ErrNoDiff, err)
+	})
+}